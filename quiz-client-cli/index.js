@@ -13,25 +13,84 @@ function printLeaderboard(leaderboard) {
 
 const JoinQuiz = "join_quiz"
 const AnswerQuestion = "answer_question"
+const ResumeSession = "resume_session"
+const TimeSync = "time_sync"
 
 const AnswerChecked = "answer_checked"
 const QuestionStarted = "question_started"
+const QuestionClosed = "question_closed"
+const QuestionEnded = "question_ended"
 const ScoreUpdated = "score_updated"
 const QuizEnded = "quiz_ended"
+const QuizPaused = "quiz_paused"
+const QuizResumed = "quiz_resumed"
+const QuestionExtended = "question_extended"
+const QuizAborted = "quiz_aborted"
+const PlayerJoined = "player_joined"
+const PlayerLeft = "player_left"
+const LobbyState = "lobby_state"
+const SessionResumed = "session_resumed"
+const MyRank = "my_rank"
 const QuizData = "quiz_data"
 const Error = "quiz_error"
 
 const JoinQuizErrorType = "JoinQuizError"
+const ResumeSessionErrorType = "ResumeSessionError"
 
 const serverPort = 8081
 const username = prompt("Enter username: ")
 
 const socket = io(`http://localhost:${serverPort}`);
 
+// the reconnect token received when joining a session, to resume it after a disconnection
+let reconnectToken
+
+const timeSyncSamples = 5
+// offset of the server clock from the client clock in milliseconds, estimated with time_sync round trips
+let clockOffset = 0
+
+// syncClock estimates the clock offset NTP-style with sequential round trips, keeping the sample with
+// the shortest round trip, which is the least skewed by the network latency, then calls done.
+// The samples are taken before prompting, as the prompts block the event loop.
+function syncClock(done, samples = timeSyncSamples, bestRoundTrip = Infinity) {
+    if (samples === 0) {
+        done()
+        return
+    }
+    socket.emit(TimeSync, Date.now(), (clientSentAt, serverReceivedAt, serverSentAt) => {
+        const clientReceivedAt = Date.now()
+        const roundTrip = (clientReceivedAt - clientSentAt) - (serverSentAt - serverReceivedAt)
+        if (roundTrip < bestRoundTrip) {
+            bestRoundTrip = roundTrip
+            clockOffset = ((serverReceivedAt - clientSentAt) + (serverSentAt - clientReceivedAt)) / 2
+        }
+        syncClock(done, samples - 1, bestRoundTrip)
+    })
+}
+
+// serverNow is the current time of the server clock as unix milliseconds.
+function serverNow() {
+    return Date.now() + clockOffset
+}
+
+function joinQuiz() {
+    reconnectToken = undefined
+    const pin = prompt("Enter quiz PIN: ")
+    if (pin === 'quit') {
+        process.exit(0)
+    }
+    socket.emit(JoinQuiz, username, pin)
+}
+
 socket.on('connect', () => {
     console.log(`Connected to server with socket ID: ${socket.id}`);
-    const quizId = prompt("Enter quiz ID: ")
-    socket.emit(JoinQuiz, username, Number(quizId))
+    syncClock(() => {
+        if (reconnectToken) {
+            socket.emit(ResumeSession, reconnectToken)
+            return
+        }
+        joinQuiz()
+    })
 });
 
 // Handle disconnection
@@ -45,40 +104,80 @@ socket.on('connect_error', (error) => {
 });
 
 let quizData
-socket.on(QuizData, (message) => {
+let sessionId
+socket.on(QuizData, (message, id, token) => {
     console.log('The quiz is starting...');
     quizData = message
+    sessionId = id
+    reconnectToken = token
+});
+
+socket.on(SessionResumed, (state) => {
+    console.log(`Resumed the quiz, your current score is: ${state.score}`)
+    if (state.question_index >= 0 && !state.answered) {
+        askQuestion(state.question_index)
+    }
 });
 
 socket.on(Error, (message) => {
     console.error(message)
-    if (message.startsWith(JoinQuizErrorType)) {
-        const quizId = prompt("Enter quiz ID: ")
-        if (quizId === 'quit') {
-            process.exit(0)
-        }
-        socket.emit(JoinQuiz, username, Number(quizId))
+    if (message.startsWith(JoinQuizErrorType) || message.startsWith(ResumeSessionErrorType)) {
+        joinQuiz()
     }
 });
 
-socket.on(QuestionStarted, (currentQuestionIndex, leaderboard) => {
-    if (currentQuestionIndex > 0) {
-        console.log(`The time for question ${currentQuestionIndex} is up!`)
-        console.log('')
-    }
+// the last argument of the session events is the server time they were sent at
+socket.on(QuestionStarted, (currentQuestionIndex, leaderboard, startedAt, deadline, sentAt) => {
+    console.log(`${Math.ceil((deadline - serverNow()) / 1000)}s to answer (received ${serverNow() - sentAt} ms after it was sent).`)
+    askQuestion(currentQuestionIndex)
+})
+
+socket.on(QuestionClosed, (currentQuestionIndex) => {
+    console.log(`The time for question ${currentQuestionIndex + 1} is up!`)
+    console.log('')
+})
+
+function askQuestion(currentQuestionIndex) {
     if (!quizData) {
         console.error("quiz data is empty")
         return
     }
-    const qc = quizData.questions[currentQuestionIndex].content
-    console.log(qc)
+    const question = quizData.questions[currentQuestionIndex]
+    console.log(question.content)
+    if (question.type === 'text') {
+        const answerStr = prompt("Your answer: ")
+        socket.emit(AnswerQuestion, JSON.stringify({
+            session_id: sessionId,
+            question_index: Number(currentQuestionIndex),
+            text_answer: answerStr
+        }))
+        return
+    }
+    question.options.forEach((option, i) => console.log(`${i + 1}) ${option.text}`))
+    if (question.type === 'multiple_choice') {
+        const answerStr = prompt("Your answers (comma separated): ")
+        socket.emit(AnswerQuestion, JSON.stringify({
+            session_id: sessionId,
+            question_index: Number(currentQuestionIndex),
+            answer_indices: answerStr.split(',').map(a => Number(a.trim()) - 1)
+        }))
+        return
+    }
     const answerStr = prompt("Your answer: ")
-    const answer = Number(answerStr)
+    const option = question.options[Number(answerStr) - 1]
     socket.emit(AnswerQuestion, JSON.stringify({
-        quiz_id: quizData.id,
+        session_id: sessionId,
         question_index: Number(currentQuestionIndex),
-        answer_index: answer - 1
+        option_id: option ? option.id : answerStr
     }))
+}
+
+socket.on(QuestionEnded, (results) => {
+    console.log(`Question ${results.question_index + 1} is over, ${results.percent_correct}% answered correctly.`)
+    ;(results.histogram || []).forEach((count, i) => console.log(`${i + 1}) ${'#'.repeat(count)} ${count}`))
+    if (results.fastest) {
+        console.log(`Fastest correct answer: ${results.fastest.username} in ${results.fastest.response_time_ms / 1000}s`)
+    }
 })
 
 socket.on(ScoreUpdated, (answeredUsername, leaderboard) => {
@@ -86,15 +185,69 @@ socket.on(ScoreUpdated, (answeredUsername, leaderboard) => {
     printLeaderboard(leaderboard)
 })
 
-socket.on(AnswerChecked, (correctAnswerIndex, newScore) => {
-    console.log('Correct answer is:', correctAnswerIndex + 1);
+socket.on(AnswerChecked, (correctAnswerIndex, newScore, result) => {
+    if (result && result.canonical_answer) {
+        console.log('Correct answer is:', result.canonical_answer);
+    } else if (result && result.correct_answer_indices) {
+        console.log('Correct answers are:', result.correct_answer_indices.map(i => i + 1).join(', '));
+    } else {
+        console.log('Correct answer is:', correctAnswerIndex + 1);
+    }
+    if (result) {
+        console.log('Points earned:', result.points)
+        if (result.streak > 1) {
+            console.log('Streak:', result.streak, 'correct answers in a row')
+        }
+    }
     console.log('Your current score is:', newScore)
+    if (result && result.rank) {
+        console.log('Your rank is:', result.rank)
+    }
     console.log()
 })
 
+socket.on(MyRank, (myRank) => {
+    console.log(`You are #${myRank.rank} of ${myRank.total_players} with ${myRank.score} points`)
+    if (myRank.above) {
+        console.log(`${myRank.above.username} is just above with ${myRank.above.score} points`)
+    }
+    if (myRank.below) {
+        console.log(`${myRank.below.username} is just below with ${myRank.below.score} points`)
+    }
+})
+
+socket.on(LobbyState, (participants) => {
+    console.log('Players in the quiz:', participants.join(', '))
+})
+
+socket.on(PlayerJoined, (joinedUsername, participants) => {
+    console.log(`${joinedUsername} joined, ${participants.length} players in the quiz`)
+})
+
+socket.on(PlayerLeft, (leftUsername, participants) => {
+    console.log(`${leftUsername} left, ${participants.length} players in the quiz`)
+})
+
+socket.on(QuizPaused, (currentQuestionIndex, remaining) => {
+    console.log(`The host paused the quiz, ${Math.ceil(remaining / 1000)}s left.`)
+})
+
+socket.on(QuizResumed, (currentQuestionIndex, startedAt, deadline) => {
+    console.log(`The host resumed the quiz, ${Math.ceil((deadline - serverNow()) / 1000)}s left.`)
+})
+
+socket.on(QuestionExtended, (currentQuestionIndex, deadline, remaining) => {
+    console.log(`The host added time, ${Math.ceil(remaining / 1000)}s left.`)
+})
+
+socket.on(QuizAborted, (reason, leaderboard) => {
+    console.log(`The quiz has been aborted: ${reason}.`)
+    printLeaderboard(leaderboard)
+    joinQuiz()
+})
+
 socket.on(QuizEnded, (leaderboard) => {
     console.log('The quiz has ended.')
     printLeaderboard(leaderboard)
-    const quizId = prompt("Enter quiz ID: ")
-    socket.emit(JoinQuiz, username, Number(quizId))
+    joinQuiz()
 })
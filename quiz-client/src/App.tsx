@@ -1,24 +1,40 @@
 import React, {useEffect, useState} from 'react';
 import './App.css';
-import {Button, Card, Col, Form, FormProps, Input, InputNumber, Layout, message, Progress, Rate, Row, Modal, FloatButton} from "antd";
-import {socket} from "./socket";
+import {Button, Card, Col, Form, FormProps, Input, InputNumber, Layout, message, Progress, Row, Modal, FloatButton} from "antd";
+import {serverNow, socket, syncClock} from "./socket";
 
 const { Content, Footer, Header } = Layout;
 
-const defaultQuestionTime = 10 // seconds
+const apiUrl = 'https://realtime-quiz-api.hungcq.xyz'
 const JoinQuiz = "join_quiz"
 const AnswerQuestion = "answer_question"
+const ResumeSession = "resume_session"
 
 const AnswerChecked = "answer_checked"
 const QuestionStarted = "question_started"
+const QuestionClosed = "question_closed"
+const QuestionEnded = "question_ended"
 const ScoreUpdated = "score_updated"
 const QuizEnded = "quiz_ended"
+const QuizPaused = "quiz_paused"
+const QuizResumed = "quiz_resumed"
+const QuestionExtended = "question_extended"
+const QuizAborted = "quiz_aborted"
+const PlayerJoined = "player_joined"
+const PlayerLeft = "player_left"
+const LobbyState = "lobby_state"
+const SessionResumed = "session_resumed"
+const MyRank = "my_rank"
 const QuizData = "quiz_data"
 const Error = "quiz_error"
 
+const ResumeSessionErrorType = "ResumeSessionError"
+// the reconnect token received when joining a session, to resume it after a disconnection or a reload
+const ReconnectTokenKey = "reconnect_token"
+
 type FieldType = {
     username?: string;
-    quizId?: string;
+    pin?: string;
 };
 
 type AnswerFieldType = {
@@ -27,15 +43,29 @@ type AnswerFieldType = {
 
 function App() {
     let [quizData, setQuizData] = useState<any>()
+    let [sessionId, setSessionId] = useState<string>('')
     let [currentQuestionIndex, setCurrentQuestionIndex] = useState(-1)
     let [leaderboard, setLeaderboard] = useState<any[]>()
-    let [timeLeft, setTimeLeft] = useState(defaultQuestionTime)
+    let [participants, setParticipants] = useState<string[]>([])
+    // answer window of the current question as unix milliseconds, and the time left while the quiz is paused
+    let [startedAt, setStartedAt] = useState(0)
+    let [deadline, setDeadline] = useState(0)
+    let [pausedRemaining, setPausedRemaining] = useState<number | null>(null)
+    let [timeLeft, setTimeLeft] = useState(0)
+    let [myRank, setMyRank] = useState<any>()
+    let [hostSessionId, setHostSessionId] = useState<string>('')
+    let [hostToken, setHostToken] = useState<string>('')
     let [isCheatModalOpen, setIsCheatModalOpen] = useState(false)
     let [cheatQuizId, setCheatQuizId] = useState<string>('')
 
     useEffect(() => {
         function onConnect() {
             console.log(`Connected to server with socket ID: ${socket.id}`);
+            syncClock()
+            const reconnectToken = localStorage.getItem(ReconnectTokenKey)
+            if (reconnectToken) {
+                socket.emit(ResumeSession, reconnectToken)
+            }
         }
 
         function onDisconnect() {
@@ -46,43 +76,119 @@ function App() {
             console.error('Connection error:', error);
         }
 
-        const onQuizStarted = (quizData: any) => {
+        const onQuizStarted = (quizData: any, sessionId: string, reconnectToken: string) => {
             setQuizData(quizData)
+            setSessionId(sessionId)
+            if (reconnectToken) {
+                localStorage.setItem(ReconnectTokenKey, reconnectToken)
+            }
         }
 
         const onQuizError = (err: any) => {
+            if (String(err).startsWith(ResumeSessionErrorType)) {
+                // the session is over, the user has to join a new one
+                localStorage.removeItem(ReconnectTokenKey)
+                return
+            }
             message.error(err)
         }
 
-        const onQuestionStarted = (currentQuestionIndex: any, leaderboard: any) => {
-            if (currentQuestionIndex > 0) {
-                message.warning(`The time for question ${currentQuestionIndex} is up!`)
+        const onSessionResumed = (state: any) => {
+            message.info(`Welcome back ${state.username}! Your current score is: ${state.score}.`)
+            setCurrentQuestionIndex(state.question_index)
+            setStartedAt(state.started_at)
+            setDeadline(state.deadline)
+            setPausedRemaining(state.paused ? state.time_remaining_ms : null)
+            if (state.answered) {
+                message.warning('You have already answered this question.')
             }
+        }
+
+        // the last argument of the session events is the server time they were sent at
+        const onQuestionStarted = (currentQuestionIndex: any, leaderboard: any, startedAt: number, deadline: number, sentAt: number) => {
+            console.log(`Question ${+currentQuestionIndex + 1} received ${serverNow() - sentAt} ms after it was sent`)
             setCurrentQuestionIndex(+currentQuestionIndex)
             setLeaderboard(leaderboard)
-            setTimeLeft(defaultQuestionTime)
-            const id = setInterval(() => {
-                setTimeLeft(prevState => {
-                    return prevState - 1
-                })
-            }, 1000)
-            setTimeout(() => {
-                clearInterval(id);
-            }, defaultQuestionTime * 1000);
+            setStartedAt(startedAt)
+            setDeadline(deadline)
+            setPausedRemaining(null)
+        }
+
+        const onQuestionClosed = (currentQuestionIndex: any) => {
+            message.warning(`The time for question ${+currentQuestionIndex + 1} is up!`)
+            setDeadline(0)
+            setPausedRemaining(null)
+        }
+
+        const onQuestionEnded = (results: any) => {
+            const correctAnswer = results.canonical_answer
+                ? results.canonical_answer
+                : results.correct_answer_indices
+                    ? results.correct_answer_indices.map((i: number) => i + 1).join(', ')
+                    : results.correct_answer_index + 1
+            const histogram = (results.histogram || []).map((count: number, i: number) => `${i + 1}: ${count}`).join(', ')
+            const fastest = results.fastest ? ` Fastest: ${results.fastest.username} (${results.fastest.response_time_ms / 1000}s).` : ''
+            message.info(`Correct answer: ${correctAnswer}. ${results.percent_correct}% answered correctly.${histogram ? ` Answers: ${histogram}.` : ''}${fastest}`, 5)
+        }
+
+        const onPlayerJoined = (joinedUsername: string, participants: string[]) => {
+            setParticipants(participants)
+        }
+
+        const onPlayerLeft = (leftUsername: string, participants: string[]) => {
+            setParticipants(participants)
         }
 
-        const onScoreUpdated = (answeredUsername: any, leaderboard: any) => {
+        const onQuizPaused = (currentQuestionIndex: any, remaining: number) => {
+            message.warning('The host paused the quiz')
+            setPausedRemaining(remaining)
+        }
+
+        const onQuizResumed = (currentQuestionIndex: any, startedAt: number, deadline: number) => {
+            message.info('The host resumed the quiz')
+            setStartedAt(startedAt)
+            setDeadline(deadline)
+            setPausedRemaining(null)
+        }
+
+        const onQuestionExtended = (currentQuestionIndex: any, deadline: number, remaining: number) => {
+            message.info('The host added time to the question')
+            setDeadline(deadline)
+            setPausedRemaining(prevState => prevState === null ? null : remaining)
+        }
+
+        const onScoreUpdated = (answeredUsername: any, leaderboard: any, sentAt: number) => {
+            console.log(`Score update received ${serverNow() - sentAt} ms after it was sent`)
             message.success(`User ${answeredUsername} answered correctly!`)
             setLeaderboard(leaderboard)
         }
 
-        const onAnswerChecked = (correctAnswerIndex: any, newScore: any) => {
-            message.info(`Correct answer is: ${correctAnswerIndex + 1}. Your current score is: ${newScore}`,);
+        const onAnswerChecked = (correctAnswerIndex: any, newScore: any, result: any) => {
+            const correctAnswer = result?.canonical_answer
+                ? result.canonical_answer
+                : result?.correct_answer_indices
+                    ? result.correct_answer_indices.map((i: number) => i + 1).join(', ')
+                    : correctAnswerIndex + 1
+            const streak = result?.streak > 1 ? ` Streak: ${result.streak} in a row!` : ''
+            message.info(`Correct answer is: ${correctAnswer}. You earned ${result?.points ?? 0} points, your current score is: ${newScore} (#${result?.rank}).${streak}`,);
+        }
+
+        const onQuizAborted = (reason: string, leaderboard: any) => {
+            message.error(`The quiz has been aborted: ${reason}.`)
+            localStorage.removeItem(ReconnectTokenKey)
+            setDeadline(0)
+            setPausedRemaining(null)
+            setLeaderboard([])
+            setQuizData(null)
+            setCurrentQuestionIndex(-1)
         }
 
-        const onQuizEnded = (leaderboard: any) => {
+        const onQuizEnded = (leaderboard: any, sentAt: number) => {
+            console.log(`Quiz end received ${serverNow() - sentAt} ms after it was sent`)
             message.info('The quiz has ended.')
-            setTimeLeft(0)
+            localStorage.removeItem(ReconnectTokenKey)
+            setDeadline(0)
+            setPausedRemaining(null)
             setTimeout(() => {
                 setLeaderboard([])
                 setQuizData(null)
@@ -96,9 +202,20 @@ function App() {
         socket.on(QuizData, onQuizStarted);
         socket.on(Error, onQuizError);
         socket.on(QuestionStarted, onQuestionStarted)
+        socket.on(QuestionClosed, onQuestionClosed)
+        socket.on(QuestionEnded, onQuestionEnded)
         socket.on(ScoreUpdated, onScoreUpdated)
         socket.on(AnswerChecked, onAnswerChecked)
         socket.on(QuizEnded, onQuizEnded)
+        socket.on(QuizPaused, onQuizPaused)
+        socket.on(QuizResumed, onQuizResumed)
+        socket.on(QuestionExtended, onQuestionExtended)
+        socket.on(QuizAborted, onQuizAborted)
+        socket.on(LobbyState, setParticipants)
+        socket.on(SessionResumed, onSessionResumed)
+        socket.on(MyRank, setMyRank)
+        socket.on(PlayerJoined, onPlayerJoined)
+        socket.on(PlayerLeft, onPlayerLeft)
 
         // Cleanup on component unmount
         return () => {
@@ -108,29 +225,67 @@ function App() {
             socket.off(QuizData, onQuizStarted);
             socket.off(Error, onQuizError);
             socket.off(QuestionStarted, onQuestionStarted)
+            socket.off(QuestionClosed, onQuestionClosed)
+            socket.off(QuestionEnded, onQuestionEnded)
             socket.off(ScoreUpdated, onScoreUpdated)
             socket.off(AnswerChecked, onAnswerChecked)
             socket.off(QuizEnded, onQuizEnded)
+            socket.off(QuizPaused, onQuizPaused)
+            socket.off(QuizResumed, onQuizResumed)
+            socket.off(QuestionExtended, onQuestionExtended)
+            socket.off(QuizAborted, onQuizAborted)
+            socket.off(LobbyState, setParticipants)
+            socket.off(SessionResumed, onSessionResumed)
+            socket.off(MyRank, setMyRank)
+            socket.off(PlayerJoined, onPlayerJoined)
+            socket.off(PlayerLeft, onPlayerLeft)
         };
     }, []);
 
+    useEffect(() => {
+        const id = setInterval(() => {
+            setTimeLeft(Math.max(0, pausedRemaining ?? deadline - serverNow()))
+        }, 100)
+        return () => clearInterval(id)
+    }, [deadline, pausedRemaining]);
+
     const onFinish: FormProps<FieldType>['onFinish'] = (values) => {
-        socket.emit(JoinQuiz, values.username, values.quizId);
+        socket.emit(JoinQuiz, values.username, String(values.pin));
     }
 
     const onSubmitAnswer: FormProps<AnswerFieldType>['onFinish'] = (values) => {
+        const question = quizData.questions[currentQuestionIndex]
+        if (question.type === 'text') {
+            socket.emit(AnswerQuestion, JSON.stringify({
+                session_id: sessionId,
+                question_index: Number(currentQuestionIndex),
+                text_answer: String(values.answer),
+            }))
+            return
+        }
+        if (question.type === 'multiple_choice') {
+            socket.emit(AnswerQuestion, JSON.stringify({
+                session_id: sessionId,
+                question_index: Number(currentQuestionIndex),
+                answer_indices: String(values.answer).split(',').map(a => Number(a.trim()) - 1),
+            }))
+            return
+        }
         socket.emit(AnswerQuestion, JSON.stringify({
-            quiz_id: quizData.id,
+            session_id: sessionId,
             question_index: Number(currentQuestionIndex),
-            answer_index: Number(values.answer) - 1,
+            option_id: question.options[Number(values.answer) - 1].id,
         }))
     }
 
     const handleCheat = async () => {
         try {
-            const response = await fetch(`https://realtime-quiz-api.hungcq.xyz/start/${cheatQuizId}`);
+            const response = await fetch(`${apiUrl}/start/${cheatQuizId}`);
             if (response.ok) {
-                message.success('Quiz started successfully!');
+                const body = await response.json()
+                setHostSessionId(body.session_id)
+                setHostToken(body.host_token)
+                message.success(`Quiz started successfully! PIN: ${body.pin}`, 10);
                 setIsCheatModalOpen(false);
                 setCheatQuizId('');
             } else {
@@ -141,6 +296,21 @@ function App() {
         }
     }
 
+    const hostAction = async (action: string, query: string = '') => {
+        try {
+            const response = await fetch(`${apiUrl}/sessions/${hostSessionId}/${action}${query}`, {
+                method: 'POST',
+                headers: {Authorization: `Bearer ${hostToken}`},
+            });
+            if (!response.ok) {
+                const body = await response.json()
+                message.error(body.error)
+            }
+        } catch (error) {
+            message.error('Error controlling quiz');
+        }
+    }
+
     return (
         <Layout style={{
             height: '100vh'
@@ -154,7 +324,18 @@ function App() {
                 padding: '0 24px',
                 position: 'relative'
             }}>
-                <div style={{ width: '100px' }}></div> {/* Spacer for balance */}
+                <div style={{ width: '100px' }}>
+                    {hostToken &&
+                        <>
+                            <Button size="small" onClick={() => hostAction('begin')}>Begin</Button>
+                            <Button size="small" onClick={() => hostAction('pause')}>Pause</Button>
+                            <Button size="small" onClick={() => hostAction('resume')}>Resume</Button>
+                            <Button size="small" onClick={() => hostAction('skip')}>Skip</Button>
+                            <Button size="small" onClick={() => hostAction('extend', '?seconds=10')}>+10s</Button>
+                            <Button size="small" danger onClick={() => hostAction('abort')}>Abort</Button>
+                        </>
+                    }
+                </div>
                 <h1 style={{ margin: 0 }}>HungCQ's Real-Time Quiz</h1>
                 <Button 
                     type="primary" 
@@ -179,9 +360,13 @@ function App() {
                                     style={{width: '100%', whiteSpace: "pre-wrap"}}>
                                     {currentQuestionIndex >= 0 &&
                                         <>
-                                            <Progress status='normal' percent={timeLeft * 10}
-                                                      format={(percent) => `${(percent || 0) / 10}s`}/>
+                                            <Progress status={pausedRemaining !== null ? 'exception' : 'normal'}
+                                                      percent={deadline > startedAt ? timeLeft / (deadline - startedAt) * 100 : 0}
+                                                      format={() => `${Math.ceil(timeLeft / 1000)}s`}/>
                                             <p>{quizData.questions[currentQuestionIndex].content}</p>
+                                            {(quizData.questions[currentQuestionIndex].options || []).map((option: any, i: number) =>
+                                                <p key={option.id}>{`${i + 1}) ${option.text}`}</p>
+                                            )}
                                             <Form
                                                 name="basic"
                                                 initialValues={{remember: true}}
@@ -195,7 +380,11 @@ function App() {
                                                     name="answer"
                                                     rules={[{required: true, message: 'Please input answer!'}]}
                                                 >
-                                                    <InputNumber max={4} min={1}/>
+                                                    {quizData.questions[currentQuestionIndex].type === 'text'
+                                                        ? <Input/>
+                                                        : quizData.questions[currentQuestionIndex].type === 'multiple_choice'
+                                                        ? <Input placeholder="eg 1,3"/>
+                                                        : <InputNumber max={quizData.questions[currentQuestionIndex].options.length} min={1}/>}
                                                 </Form.Item>
 
                                                 <Form.Item>
@@ -209,12 +398,24 @@ function App() {
                                 </Card>
                             </Col>
                             <Col span={8}>
-                                <Card title="Leaderboard" style={{width: '100%'}}>
-                                    {leaderboard && leaderboard.map((item: any) =>
-                                        <h3>{`${item.username} `}<Rate count={item.score} value={item.score}/></h3>
-                                    )
-                                    }
-                                </Card>
+                                {currentQuestionIndex < 0 ?
+                                    <Card title={`Players (${participants.length})`} style={{width: '100%'}}>
+                                        {participants.map((participant: string) =>
+                                            <h3 key={participant}>{participant}</h3>
+                                        )}
+                                    </Card>
+                                    :
+                                    <Card title="Leaderboard" style={{width: '100%'}}>
+                                        {leaderboard && leaderboard.map((item: any) =>
+                                            <h3 key={item.username}>{`${item.username}: ${item.score}`}</h3>
+                                        )
+                                        }
+                                        {myRank &&
+                                            <p>{`You are #${myRank.rank} of ${myRank.total_players} with ${myRank.score} points`}
+                                                {myRank.above && ` (${myRank.above.username} above: ${myRank.above.score})`}</p>
+                                        }
+                                    </Card>
+                                }
                             </Col>
                         </>
                         :
@@ -237,11 +438,11 @@ function App() {
                                 </Form.Item>
 
                                 <Form.Item<FieldType>
-                                    label="Quiz ID"
-                                    name="quizId"
-                                    rules={[{required: true, message: 'Please input quiz ID!'}]}
+                                    label="Quiz PIN"
+                                    name="pin"
+                                    rules={[{required: true, message: 'Please input quiz PIN!'}]}
                                 >
-                                    <InputNumber style={{ width: '100%' }}/>
+                                    <Input/>
                                 </Form.Item>
 
                                 <Form.Item label={null}>
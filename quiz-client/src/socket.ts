@@ -1,3 +1,32 @@
 import io from "socket.io-client";
 
-export const socket = io('wss://realtime-quiz-api.hungcq.xyz');
\ No newline at end of file
+export const socket = io('wss://realtime-quiz-api.hungcq.xyz');
+
+export const TimeSync = "time_sync"
+const timeSyncSamples = 5
+
+// offset of the server clock from the client clock in milliseconds, estimated with time_sync round trips
+let clockOffset = 0
+
+// syncClock estimates the clock offset NTP-style, keeping the sample with the shortest round trip,
+// which is the least skewed by the network latency.
+export function syncClock() {
+    let bestRoundTrip = Infinity
+    for (let i = 0; i < timeSyncSamples; i++) {
+        setTimeout(() => {
+            socket.emit(TimeSync, Date.now(), (clientSentAt: number, serverReceivedAt: number, serverSentAt: number) => {
+                const clientReceivedAt = Date.now()
+                const roundTrip = (clientReceivedAt - clientSentAt) - (serverSentAt - serverReceivedAt)
+                if (roundTrip < bestRoundTrip) {
+                    bestRoundTrip = roundTrip
+                    clockOffset = ((serverReceivedAt - clientSentAt) + (serverSentAt - clientReceivedAt)) / 2
+                }
+            })
+        }, i * 200)
+    }
+}
+
+// serverNow is the current time of the server clock as unix milliseconds.
+export function serverNow() {
+    return Date.now() + clockOffset
+}
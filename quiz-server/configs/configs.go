@@ -1,7 +1,12 @@
 package configs
 
 import (
+	"crypto/rand"
+	"errors"
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -12,6 +17,40 @@ var RedisAddress = os.Getenv("REDIS_ADDRESS")
 
 var TemporalAddress = os.Getenv("TEMPORAL_ADDRESS")
 
+// QuizStore selects the quiz content repository: memory, file or sql.
+// The quizzes created through the API in memory only exist on the instance which created them,
+// several instances need a shared directory or database.
+var QuizStore = os.Getenv("QUIZ_STORE")
+
+// QuizStoreDir is the directory of JSON/YAML quiz files used by the file quiz store.
+var QuizStoreDir = os.Getenv("QUIZ_STORE_DIR")
+
+// ResultsStore selects the repository of the results of the ended sessions: sql.
+// The workflow worker saves the results which the API serves, so both need the same sql database.
+var ResultsStore = os.Getenv("RESULTS_STORE")
+
+// DatabaseDriver is the SQL driver used by the sql quiz and results stores: sqlite or postgres.
+var DatabaseDriver = os.Getenv("DATABASE_DRIVER")
+
+var DatabaseDSN = os.Getenv("DATABASE_DSN")
+
+// AdminToken authenticates the quiz authors on the quiz authoring API. The API is read-only, without the answers,
+// if it is not set.
+var AdminToken = os.Getenv("QUIZ_ADMIN_TOKEN")
+
+// ReconnectSecret signs the reconnect tokens, it must be the same on all the instances.
+var ReconnectSecret = []byte(os.Getenv("RECONNECT_SECRET"))
+
+// DevMode, set by DEV_MODE=true, allows running the server without its secrets, eg locally.
+var DevMode = os.Getenv("DEV_MODE") == "true"
+
+// placeholderSecrets are sample values which must never be used as secrets.
+var placeholderSecrets = []string{"change-me", "changeme", "secret", "password"}
+
+// AnswerGracePeriod is the time after the deadline of a question during which answers are still accepted,
+// to absorb the network latency of the players. Set in milliseconds by ANSWER_GRACE_PERIOD_MS.
+var AnswerGracePeriod = 500 * time.Millisecond
+
 func init() {
 	if KafkaBrokerAddress[0] == "" {
 		KafkaBrokerAddress = []string{"localhost:9092"}
@@ -22,17 +61,92 @@ func init() {
 	if TemporalAddress == "" {
 		TemporalAddress = "localhost:7233"
 	}
+	if QuizStore == "" {
+		QuizStore = "memory"
+	}
+	if ResultsStore == "" {
+		ResultsStore = "sql"
+	}
+	if QuizStoreDir == "" {
+		QuizStoreDir = "quizzes"
+	}
+	if DatabaseDriver == "" {
+		DatabaseDriver = "sqlite"
+	}
+	if DatabaseDSN == "" {
+		DatabaseDSN = "quiz.db"
+	}
+	if gracePeriod := os.Getenv("ANSWER_GRACE_PERIOD_MS"); gracePeriod != "" {
+		ms, err := strconv.Atoi(gracePeriod)
+		if err != nil || ms < 0 {
+			panic(fmt.Sprintf("invalid ANSWER_GRACE_PERIOD_MS: %s", gracePeriod))
+		}
+		AnswerGracePeriod = time.Duration(ms) * time.Millisecond
+	}
+}
+
+// CheckSecrets returns an error if the reconnect secret is not set, or if a secret is a placeholder,
+// unless in dev mode. In dev mode, a missing reconnect secret is generated, so that the reconnect tokens
+// are only valid on this instance.
+func CheckSecrets() error {
+	if !DevMode {
+		if len(ReconnectSecret) == 0 {
+			return errors.New("RECONNECT_SECRET is required, set DEV_MODE=true to run without it")
+		}
+		if slices.Contains(placeholderSecrets, strings.ToLower(string(ReconnectSecret))) {
+			return errors.New("RECONNECT_SECRET is a placeholder value")
+		}
+		if slices.Contains(placeholderSecrets, strings.ToLower(AdminToken)) {
+			return errors.New("QUIZ_ADMIN_TOKEN is a placeholder value")
+		}
+	}
+	if AdminToken == "" {
+		fmt.Println("QUIZ_ADMIN_TOKEN is not set, quizzes cannot be edited through the API")
+	}
+	if len(ReconnectSecret) == 0 {
+		fmt.Println("RECONNECT_SECRET is not set, reconnect tokens are only valid on this instance")
+		ReconnectSecret = make([]byte, 32)
+		if _, err := rand.Read(ReconnectSecret); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 const (
-	QuizProgressedTopic = "quiz_progressed"
-	ScoreUpdatedTopic   = "score_updated"
+	QuizProgressedTopic     = "quiz_progressed"
+	ScoreUpdatedTopic       = "score_updated"
+	ParticipantChangedTopic = "participant_changed"
 )
 
+// The lobby, question and reveal durations are used unless the quiz configures its own.
+// The runtime state of a quiz session expires QuizLockMargin after the session should have ended.
 const (
+	DefaultLobbyTime    = 10 * time.Second
 	DefaultQuestionTime = 10 * time.Second
-	QuizMaxDuration     = 5 * time.Minute
+	DefaultRevealTime   = 5 * time.Second
+	QuizLockMargin      = time.Minute
 	LeaderboardSize     = 5
+	// MaxPauseDuration is the time after which a paused quiz resumes automatically
+	MaxPauseDuration = 10 * time.Minute
+	// MaxLobbyTime is the time after which a quiz waiting for its host to begin starts automatically
+	MaxLobbyTime = 30 * time.Minute
+	// ScoreRetention is the time the leaderboard of an ended quiz is kept, for the players to get their final rank
+	ScoreRetention = time.Minute
+)
+
+// The score of a correct answer decreases linearly from MaxQuestionScore when the question starts
+// to MinQuestionScore at the deadline, unless the quiz configures its own scoring.
+const (
+	MaxQuestionScore = 1000
+	MinQuestionScore = 500
+)
+
+// Each consecutive correct answer after the first adds StreakBonus to the score multiplier,
+// up to MaxStreakBonus, unless the quiz configures its own scoring.
+const (
+	StreakBonus    = 0.1
+	MaxStreakBonus = 0.5
 )
 
 type SocketEvent string
@@ -41,11 +155,25 @@ const (
 	// inbound events
 	JoinQuiz       SocketEvent = "join_quiz"
 	AnswerQuestion SocketEvent = "answer_question"
+	ResumeSession  SocketEvent = "resume_session"
+	// TimeSync is acknowledged with the server clock, or answered with a time_sync event if the client sends no ack
+	TimeSync SocketEvent = "time_sync"
 	// outbound events
-	AnswerChecked   SocketEvent = "answer_checked"
-	QuestionStarted SocketEvent = "question_started"
-	ScoreUpdated    SocketEvent = "score_updated"
-	QuizEnded       SocketEvent = "quiz_ended"
-	QuizData        SocketEvent = "quiz_data"
-	Error           SocketEvent = "quiz_error"
+	AnswerChecked    SocketEvent = "answer_checked"
+	QuestionStarted  SocketEvent = "question_started"
+	QuestionClosed   SocketEvent = "question_closed"
+	QuestionEnded    SocketEvent = "question_ended"
+	ScoreUpdated     SocketEvent = "score_updated"
+	QuizEnded        SocketEvent = "quiz_ended"
+	QuizPaused       SocketEvent = "quiz_paused"
+	QuizResumed      SocketEvent = "quiz_resumed"
+	QuestionExtended SocketEvent = "question_extended"
+	QuizAborted      SocketEvent = "quiz_aborted"
+	PlayerJoined     SocketEvent = "player_joined"
+	PlayerLeft       SocketEvent = "player_left"
+	LobbyState       SocketEvent = "lobby_state"
+	SessionResumed   SocketEvent = "session_resumed"
+	MyRank           SocketEvent = "my_rank"
+	QuizData         SocketEvent = "quiz_data"
+	Error            SocketEvent = "quiz_error"
 )
@@ -0,0 +1,45 @@
+package consumers
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/IBM/sarama"
+	"quiz/core/managers"
+	"quiz/core/models"
+)
+
+type ParticipantChangedEventHandler struct {
+	quizSessionManager *managers.QuizSession
+}
+
+func NewParticipantChangedEventHandler(quizSessionManager *managers.QuizSession) *ParticipantChangedEventHandler {
+	return &ParticipantChangedEventHandler{quizSessionManager: quizSessionManager}
+}
+
+func (q *ParticipantChangedEventHandler) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	for msg := range claim.Messages() {
+		fmt.Println("received participant changed event:", string(msg.Value))
+		event := &models.ParticipantChangedEvent{}
+		if err := json.Unmarshal(msg.Value, event); err != nil {
+			fmt.Println("error parsing participant changed event", err, string(msg.Value))
+		}
+		if err := q.handleMsg(event); err != nil {
+			fmt.Println("error handling participant changed event", err, string(msg.Value))
+		}
+		session.MarkMessage(msg, "")
+	}
+	return nil
+}
+
+func (q *ParticipantChangedEventHandler) handleMsg(event *models.ParticipantChangedEvent) error {
+	return q.quizSessionManager.OnParticipantChanged(event)
+}
+
+func (q *ParticipantChangedEventHandler) Setup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
+
+func (q *ParticipantChangedEventHandler) Cleanup(session sarama.ConsumerGroupSession) error {
+	return nil
+}
@@ -0,0 +1,82 @@
+package data
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"quiz/core/models"
+)
+
+// InMemoryQuizRepository keeps the quizzes in a map. It is used for tests and local development.
+type InMemoryQuizRepository struct {
+	quizzes map[models.QuizId]*models.Quiz
+	mutex   sync.RWMutex
+}
+
+func NewInMemoryQuizRepository(quizzes map[models.QuizId]*models.Quiz) *InMemoryQuizRepository {
+	repo := &InMemoryQuizRepository{
+		quizzes: make(map[models.QuizId]*models.Quiz, len(quizzes)),
+	}
+	for id, quiz := range quizzes {
+		repo.quizzes[id] = quiz
+	}
+	return repo
+}
+
+func (r *InMemoryQuizRepository) GetQuiz(ctx context.Context, quizId models.QuizId) (*models.Quiz, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	quiz := r.quizzes[quizId]
+	if quiz == nil {
+		return nil, ErrQuizNotFound
+	}
+	return quiz, nil
+}
+
+func (r *InMemoryQuizRepository) ListQuizzes(ctx context.Context) ([]*models.Quiz, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	res := make([]*models.Quiz, 0, len(r.quizzes))
+	for _, quiz := range r.quizzes {
+		res = append(res, quiz)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id < res[j].Id
+	})
+	return res, nil
+}
+
+func (r *InMemoryQuizRepository) CreateQuiz(ctx context.Context, quiz *models.Quiz) (*models.Quiz, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var maxId models.QuizId
+	for id := range r.quizzes {
+		maxId = max(maxId, id)
+	}
+	created := *quiz
+	created.Id = maxId + 1
+	r.quizzes[created.Id] = &created
+	return &created, nil
+}
+
+func (r *InMemoryQuizRepository) UpdateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.quizzes[quiz.Id] == nil {
+		return ErrQuizNotFound
+	}
+	updated := *quiz
+	r.quizzes[quiz.Id] = &updated
+	return nil
+}
+
+func (r *InMemoryQuizRepository) DeleteQuiz(ctx context.Context, quizId models.QuizId) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.quizzes[quizId] == nil {
+		return ErrQuizNotFound
+	}
+	delete(r.quizzes, quizId)
+	return nil
+}
@@ -11,43 +11,53 @@ var programmingQuiz = &models.Quiz{
 	Id: 1,
 	Questions: []models.Question{
 		{
-			Content: `What is "polymorphism" in object-oriented programming?
-1) The process of breaking a program into smaller modules.
-2) The ability of a single function or class to operate on different data types.
-3) The method of hiding implementation details from the user.
-4) A technique to establish relationships between two classes.`,
+			Content: `What is "polymorphism" in object-oriented programming?`,
+			Options: []models.Option{
+				{Id: "a", Text: "The process of breaking a program into smaller modules."},
+				{Id: "b", Text: "The ability of a single function or class to operate on different data types."},
+				{Id: "c", Text: "The method of hiding implementation details from the user."},
+				{Id: "d", Text: "A technique to establish relationships between two classes."},
+			},
 			CorrectAnswerIndex: 1,
 		},
 		{
-			Content: `What does the term "idempotent" mean in programming?
-1) An operation that can be applied multiple times without changing the result beyond the initial application.
-2) A function that depends only on its arguments and produces the same output every time.
-3) A program that completes a task only once regardless of the input.
-4) An algorithm that guarantees no duplicate results in a dataset.`,
+			Content: `What does the term "idempotent" mean in programming?`,
+			Options: []models.Option{
+				{Id: "a", Text: "An operation that can be applied multiple times without changing the result beyond the initial application."},
+				{Id: "b", Text: "A function that depends only on its arguments and produces the same output every time."},
+				{Id: "c", Text: "A program that completes a task only once regardless of the input."},
+				{Id: "d", Text: "An algorithm that guarantees no duplicate results in a dataset."},
+			},
 			CorrectAnswerIndex: 0,
 		},
 		{
-			Content: `In databases, what does "ACID" stand for?
-1) Automatic, Consistent, Immediate, Durable
-2) Atomicity, Consistency, Isolation, Durability
-3) Asynchronous, Concurrent, Immediate, Durable
-4) Availability, Compliance, Integrity, Design`,
+			Content: `In databases, what does "ACID" stand for?`,
+			Options: []models.Option{
+				{Id: "a", Text: "Automatic, Consistent, Immediate, Durable"},
+				{Id: "b", Text: "Atomicity, Consistency, Isolation, Durability"},
+				{Id: "c", Text: "Asynchronous, Concurrent, Immediate, Durable"},
+				{Id: "d", Text: "Availability, Compliance, Integrity, Design"},
+			},
 			CorrectAnswerIndex: 1,
 		},
 		{
-			Content: `What is a "deadlock" in concurrent programming?
-1) A situation where two processes wait indefinitely for each other to release resources.
-2) A mechanism that ensures tasks are executed in order.
-3) A state where one process halts execution due to memory shortage.
-4) A technique to prioritize tasks based on urgency.`,
+			Content: `What is a "deadlock" in concurrent programming?`,
+			Options: []models.Option{
+				{Id: "a", Text: "A situation where two processes wait indefinitely for each other to release resources."},
+				{Id: "b", Text: "A mechanism that ensures tasks are executed in order."},
+				{Id: "c", Text: "A state where one process halts execution due to memory shortage."},
+				{Id: "d", Text: "A technique to prioritize tasks based on urgency."},
+			},
 			CorrectAnswerIndex: 0,
 		},
 		{
-			Content: `What is "inheritance" in object-oriented programming?
-1) Encapsulation of data in classes.
-2) The ability to reuse methods and properties from one class in another class.
-3) A method for enforcing access control in classes.
-4) A feature to create anonymous functions.`,
+			Content: `What is "inheritance" in object-oriented programming?`,
+			Options: []models.Option{
+				{Id: "a", Text: "Encapsulation of data in classes."},
+				{Id: "b", Text: "The ability to reuse methods and properties from one class in another class."},
+				{Id: "c", Text: "A method for enforcing access control in classes."},
+				{Id: "d", Text: "A feature to create anonymous functions."},
+			},
 			CorrectAnswerIndex: 1,
 		},
 	},
@@ -57,44 +67,62 @@ var elementaryEnglishQuiz = &models.Quiz{
 	Id: 2,
 	Questions: []models.Question{
 		{
-			Content: `What is the plural form of the word "child"?
-1) Childs
-2) Childrens
-3) Children
-4) Childer`,
+			Content: `What is the plural form of the word "child"?`,
+			Options: []models.Option{
+				{Id: "a", Text: "Childs"},
+				{Id: "b", Text: "Childrens"},
+				{Id: "c", Text: "Children"},
+				{Id: "d", Text: "Childer"},
+			},
 			CorrectAnswerIndex: 2,
 		},
 		{
-			Content: `Which of these is a synonym for "happy"?
-1) Sad
-2) Joyful
-3) Angry
-4) Tired`,
+			Content: `Which of these is a synonym for "happy"?`,
+			Options: []models.Option{
+				{Id: "a", Text: "Sad"},
+				{Id: "b", Text: "Joyful"},
+				{Id: "c", Text: "Angry"},
+				{Id: "d", Text: "Tired"},
+			},
 			CorrectAnswerIndex: 1,
 		},
 		{
-			Content: `What is the correct article to use before the word "apple"?
-1) A
-2) An
-3) The
-4) None`,
+			Content: `What is the correct article to use before the word "apple"?`,
+			Options: []models.Option{
+				{Id: "a", Text: "A"},
+				{Id: "b", Text: "An"},
+				{Id: "c", Text: "The"},
+				{Id: "d", Text: "None"},
+			},
 			CorrectAnswerIndex: 1,
 		},
 		{
-			Content: `Which sentence is grammatically correct?
-1) She don’t like apples.
-2) She doesn’t likes apples.
-3) She doesn’t like apples.
-4) She don’t likes apples.`,
+			Content: "Which sentence is grammatically correct?",
+			Options: []models.Option{
+				{Id: "a", Text: "She don’t like apples."},
+				{Id: "b", Text: "She doesn’t likes apples."},
+				{Id: "c", Text: "She doesn’t like apples."},
+				{Id: "d", Text: "She don’t likes apples."},
+			},
 			CorrectAnswerIndex: 2,
 		},
 		{
-			Content: `What is the past tense of the verb "run"?
-1) Runs
-2) Running
-3) Ran
-4) Runned`,
+			Content: `What is the past tense of the verb "run"?`,
+			Options: []models.Option{
+				{Id: "a", Text: "Runs"},
+				{Id: "b", Text: "Running"},
+				{Id: "c", Text: "Ran"},
+				{Id: "d", Text: "Runned"},
+			},
 			CorrectAnswerIndex: 2,
 		},
+		{
+			Type:            models.Text,
+			Content:         "Type the English word for a young dog.",
+			AcceptedAnswers: []string{"puppy", "pup"},
+			Matching:        &models.TextMatching{MaxTypos: 1},
+			// typing takes longer than picking an option
+			TimeLimitSeconds: 20,
+		},
 	},
 }
@@ -0,0 +1,31 @@
+package data
+
+import (
+	"context"
+	"errors"
+
+	"quiz/core/models"
+)
+
+var ErrQuizNotFound = errors.New("quiz not found")
+
+// QuizRepository provides access to the quiz content.
+// Implementations must return ErrQuizNotFound if the requested quiz does not exist.
+type QuizRepository interface {
+	GetQuiz(ctx context.Context, quizId models.QuizId) (*models.Quiz, error)
+	ListQuizzes(ctx context.Context) ([]*models.Quiz, error)
+	// CreateQuiz stores a new quiz and returns it with the ID assigned by the repository.
+	CreateQuiz(ctx context.Context, quiz *models.Quiz) (*models.Quiz, error)
+	UpdateQuiz(ctx context.Context, quiz *models.Quiz) error
+	DeleteQuiz(ctx context.Context, quizId models.QuizId) error
+}
+
+var ErrResultsNotFound = errors.New("session results not found")
+
+// ResultsRepository stores the final results of the ended quiz sessions.
+// Implementations must return ErrResultsNotFound if the results of the requested session are not stored.
+type ResultsRepository interface {
+	// SaveResults stores the results of a session, replacing the ones already stored for the session if any.
+	SaveResults(ctx context.Context, results *models.SessionResults) error
+	GetResults(ctx context.Context, sessionId models.SessionId) (*models.SessionResults, error)
+}
@@ -0,0 +1,210 @@
+package managers
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"quiz/configs"
+	"quiz/core/data"
+	"quiz/core/models"
+	"quiz/core/questions"
+	"quiz/datastore"
+)
+
+// QuizContent manages the quiz definitions: creating, editing and deleting quizzes and their questions.
+type QuizContent struct {
+	quizRepository data.QuizRepository
+}
+
+func NewQuizContentManager(quizRepository data.QuizRepository) *QuizContent {
+	return &QuizContent{
+		quizRepository: quizRepository,
+	}
+}
+
+var ErrQuestionNotFound = errors.New("question not found")
+
+var ErrInvalidAdminToken = errors.New("invalid admin token")
+
+var ErrQuizBeingEdited = errors.New("quiz is being edited, try again")
+
+// The edits of a quiz wait up to editLockAttempts * editLockRetryDelay for the edit in progress to complete.
+// The edit lock expires after editLockExpiration in case an instance dies while holding it.
+const (
+	editLockAttempts   = 20
+	editLockRetryDelay = 50 * time.Millisecond
+	editLockExpiration = 10 * time.Second
+)
+
+// CheckAdminToken authenticates a quiz author. All the tokens are rejected if no admin token is configured.
+func CheckAdminToken(token string) error {
+	if configs.AdminToken == "" || subtle.ConstantTimeCompare([]byte(configs.AdminToken), []byte(token)) != 1 {
+		return ErrInvalidAdminToken
+	}
+	return nil
+}
+
+func (m *QuizContent) ListQuizzes(ctx context.Context) ([]*models.Quiz, error) {
+	return m.quizRepository.ListQuizzes(ctx)
+}
+
+func (m *QuizContent) GetQuiz(ctx context.Context, quizId models.QuizId) (*models.Quiz, error) {
+	return m.quizRepository.GetQuiz(ctx, quizId)
+}
+
+func (m *QuizContent) CreateQuiz(ctx context.Context, quiz *models.Quiz) (*models.Quiz, error) {
+	for i := range quiz.Questions {
+		assignOptionIds(&quiz.Questions[i])
+	}
+	if err := questions.ValidateQuiz(quiz); err != nil {
+		return nil, err
+	}
+	return m.quizRepository.CreateQuiz(ctx, quiz)
+}
+
+func (m *QuizContent) UpdateQuiz(ctx context.Context, quiz *models.Quiz) (*models.Quiz, error) {
+	for i := range quiz.Questions {
+		assignOptionIds(&quiz.Questions[i])
+	}
+	if err := questions.ValidateQuiz(quiz); err != nil {
+		return nil, err
+	}
+	err := m.withEditLock(ctx, quiz.Id, func() error {
+		if err := m.checkNotInProgress(ctx, quiz.Id); err != nil {
+			return err
+		}
+		return m.quizRepository.UpdateQuiz(ctx, quiz)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quiz, nil
+}
+
+func (m *QuizContent) DeleteQuiz(ctx context.Context, quizId models.QuizId) error {
+	return m.withEditLock(ctx, quizId, func() error {
+		if err := m.checkNotInProgress(ctx, quizId); err != nil {
+			return err
+		}
+		return m.quizRepository.DeleteQuiz(ctx, quizId)
+	})
+}
+
+func (m *QuizContent) GetQuestion(ctx context.Context, quizId models.QuizId, questionIndex int) (*models.Question, error) {
+	quiz, err := m.quizRepository.GetQuiz(ctx, quizId)
+	if err != nil {
+		return nil, err
+	}
+	if questionIndex < 0 || questionIndex >= len(quiz.Questions) {
+		return nil, ErrQuestionNotFound
+	}
+	return &quiz.Questions[questionIndex], nil
+}
+
+// AddQuestion appends the question to the quiz and returns the index of the new question.
+func (m *QuizContent) AddQuestion(ctx context.Context, quizId models.QuizId, question *models.Question) (int, error) {
+	assignOptionIds(question)
+	index := -1
+	err := m.editQuestions(ctx, quizId, func(questions []models.Question) ([]models.Question, error) {
+		index = len(questions)
+		return append(questions, *question), nil
+	})
+	return index, err
+}
+
+func (m *QuizContent) UpdateQuestion(ctx context.Context, quizId models.QuizId, questionIndex int, question *models.Question) error {
+	assignOptionIds(question)
+	return m.editQuestions(ctx, quizId, func(questions []models.Question) ([]models.Question, error) {
+		if questionIndex < 0 || questionIndex >= len(questions) {
+			return nil, ErrQuestionNotFound
+		}
+		questions[questionIndex] = *question
+		return questions, nil
+	})
+}
+
+func (m *QuizContent) DeleteQuestion(ctx context.Context, quizId models.QuizId, questionIndex int) error {
+	return m.editQuestions(ctx, quizId, func(questions []models.Question) ([]models.Question, error) {
+		if questionIndex < 0 || questionIndex >= len(questions) {
+			return nil, ErrQuestionNotFound
+		}
+		return append(questions[:questionIndex], questions[questionIndex+1:]...), nil
+	})
+}
+
+// editQuestions applies the edit to a copy of the quiz questions, then validates and saves the quiz.
+// The quiz is read and saved under its edit lock, so that concurrent edits don't overwrite each other.
+func (m *QuizContent) editQuestions(
+	ctx context.Context, quizId models.QuizId, edit func(questions []models.Question) ([]models.Question, error),
+) error {
+	return m.withEditLock(ctx, quizId, func() error {
+		quiz, err := m.quizRepository.GetQuiz(ctx, quizId)
+		if err != nil {
+			return err
+		}
+		if err = m.checkNotInProgress(ctx, quizId); err != nil {
+			return err
+		}
+		updated := *quiz
+		updated.Questions, err = edit(append([]models.Question(nil), quiz.Questions...))
+		if err != nil {
+			return err
+		}
+		if err = questions.ValidateQuiz(&updated); err != nil {
+			return err
+		}
+		return m.quizRepository.UpdateQuiz(ctx, &updated)
+	})
+}
+
+// withEditLock runs the edit of the quiz while holding its edit lock in Redis, shared by all the instances.
+func (m *QuizContent) withEditLock(ctx context.Context, quizId models.QuizId, edit func() error) error {
+	token := uuid.NewString()
+	for attempt := 0; ; attempt++ {
+		ok, err := datastore.LockQuizEdit(ctx, quizId, token, editLockExpiration)
+		if err != nil {
+			return err
+		}
+		if ok {
+			break
+		}
+		if attempt+1 >= editLockAttempts {
+			return ErrQuizBeingEdited
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(editLockRetryDelay):
+		}
+	}
+	defer func() {
+		if err := datastore.UnlockQuizEdit(context.Background(), quizId, token); err != nil {
+			fmt.Println("error releasing quiz edit lock", quizId, err)
+		}
+	}()
+	return edit()
+}
+
+// assignOptionIds gives the new options an ID. Existing IDs are kept,
+// so the answers submitted by option ID stay valid when the question is edited.
+func assignOptionIds(question *models.Question) {
+	for i := range question.Options {
+		if question.Options[i].Id == "" {
+			question.Options[i].Id = uuid.NewString()
+		}
+	}
+}
+
+// checkNotInProgress prevents editing a quiz while a session of it is running,
+// since the participants have already received its questions.
+func (m *QuizContent) checkNotInProgress(ctx context.Context, quizId models.QuizId) error {
+	err := datastore.CheckQuizInProgress(ctx, quizId)
+	if errors.Is(err, datastore.ErrQuizInProgress) {
+		return QuizInProgressError
+	}
+	return err
+}
@@ -0,0 +1,180 @@
+package managers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"quiz/configs"
+	"quiz/core/models"
+	"quiz/datastore"
+	"quiz/event_publisher"
+)
+
+var ErrInvalidHostToken = errors.New("invalid host token")
+
+var ErrInvalidPin = errors.New("invalid PIN")
+
+// pinAttempts is the number of random PINs tried before giving up, in case they are already used by other sessions.
+const pinAttempts = 10
+
+// CreateHostToken generates the token authenticating the host of a quiz session,
+// ie the user who started it, to control the session.
+func CreateHostToken(ctx context.Context, sessionId models.SessionId, expiration time.Duration) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	if err := datastore.SaveHostToken(ctx, sessionId, token, expiration); err != nil {
+		return "", fmt.Errorf("error saving host token: %w", err)
+	}
+	return token, nil
+}
+
+// CreateSessionPin generates the random 6-digit PIN that participants enter to join the quiz session.
+func CreateSessionPin(ctx context.Context, sessionId models.SessionId, quizId models.QuizId, expiration time.Duration) (string, error) {
+	session := &models.SessionPin{SessionId: sessionId, QuizId: quizId}
+	for range pinAttempts {
+		n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+		if err != nil {
+			return "", err
+		}
+		pin := fmt.Sprintf("%06d", n.Int64())
+		ok, err := datastore.SaveSessionPin(ctx, pin, session, expiration)
+		if err != nil {
+			return "", fmt.Errorf("error saving session PIN: %w", err)
+		}
+		if ok {
+			return pin, nil
+		}
+	}
+	return "", errors.New("no PIN available")
+}
+
+// ResolvePin returns the quiz session of the PIN, or ErrInvalidPin if no session in progress has this PIN.
+func ResolvePin(ctx context.Context, pin string) (*models.SessionPin, error) {
+	session, err := datastore.GetSessionPin(ctx, pin)
+	if errors.Is(err, datastore.ErrPinNotFound) {
+		return nil, ErrInvalidPin
+	}
+	return session, err
+}
+
+func CheckHostToken(ctx context.Context, sessionId models.SessionId, token string) error {
+	hostToken, err := datastore.GetHostToken(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	if hostToken == "" || subtle.ConstantTimeCompare([]byte(hostToken), []byte(token)) != 1 {
+		return ErrInvalidHostToken
+	}
+	return nil
+}
+
+// NewQuizSession reserves the next run number of the quiz, and returns the ID of the new session.
+// Several sessions of the same quiz can run concurrently.
+func NewQuizSession(ctx context.Context, quizId models.QuizId) (models.SessionId, error) {
+	run, err := datastore.NextQuizRun(ctx, quizId)
+	if err != nil {
+		return "", err
+	}
+	return models.NewSessionId(quizId, run), nil
+}
+
+// DiscardQuizSession releases the host token and the PIN of a session whose workflow failed to start.
+func DiscardQuizSession(ctx context.Context, sessionId models.SessionId) {
+	if err := datastore.MarkQuizAsFinished(ctx, sessionId); err != nil {
+		fmt.Println("error discarding quiz session", sessionId, err)
+	}
+}
+
+// GetLastQuizRun returns the run number of the current or last session of the quiz, 0 if it has never been started.
+func GetLastQuizRun(ctx context.Context, quizId models.QuizId) (int64, error) {
+	return datastore.GetLastQuizRun(ctx, quizId)
+}
+
+func CountParticipants(ctx context.Context, sessionId models.SessionId) (int64, error) {
+	return datastore.CountParticipants(ctx, sessionId)
+}
+
+// PauseQuiz stops the countdown of the current question until the host resumes the quiz,
+// extending the quiz lock by the maximum pause duration, which ResumeQuiz adjusts to the actual pause.
+func PauseQuiz(ctx context.Context, sessionId models.SessionId, questionIndex int, remaining time.Duration) error {
+	fmt.Println("pause quiz", sessionId, questionIndex)
+	if err := datastore.ExtendQuizLock(ctx, sessionId, configs.MaxPauseDuration); err != nil {
+		return err
+	}
+	if err := datastore.PauseQuestionWindow(ctx, sessionId, remaining); err != nil {
+		return err
+	}
+	quizProgressed := &models.QuizProgressedEvent{
+		SessionId:     sessionId,
+		QuestionIndex: questionIndex,
+		EventType:     models.QuizPaused,
+		Remaining:     remaining,
+	}
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
+}
+
+// ResumeQuiz restarts the countdown of the current phase. The answer window of the question is shifted
+// by the pause duration if the quiz is resumed while answering, it is left as is in the lobby and the reveal.
+// The quiz lock, extended by the maximum pause duration when pausing, ends up extended by the pause duration.
+func ResumeQuiz(
+	ctx context.Context, sessionId models.SessionId, questionIndex int, startedAt, deadline time.Time, pausedFor time.Duration,
+	answering bool,
+) error {
+	fmt.Println("resume quiz", sessionId, questionIndex, pausedFor)
+	// the pause duration is unknown if the quiz was paused before it was recorded, the extension is then kept
+	if pausedFor > 0 {
+		if err := datastore.ExtendQuizLock(ctx, sessionId, pausedFor-configs.MaxPauseDuration); err != nil {
+			return err
+		}
+	}
+	if answering {
+		if err := datastore.ResumeQuestionWindow(ctx, sessionId, startedAt, deadline); err != nil {
+			return err
+		}
+	}
+	quizProgressed := &models.QuizProgressedEvent{
+		SessionId:     sessionId,
+		QuestionIndex: questionIndex,
+		EventType:     models.QuizResumed,
+		StartedAt:     startedAt,
+		Deadline:      deadline,
+	}
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
+}
+
+// CancelPause reduces the extension of the quiz lock to the pause duration when the host skips a paused phase.
+// The skipped question is closed, or the next one started, by the workflow.
+func CancelPause(ctx context.Context, sessionId models.SessionId, questionIndex int, pausedFor time.Duration) error {
+	fmt.Println("cancel pause", sessionId, questionIndex, pausedFor)
+	return datastore.ExtendQuizLock(ctx, sessionId, pausedFor-configs.MaxPauseDuration)
+}
+
+// ExtendQuestion notifies the new deadline of the current question after the host adds time.
+func ExtendQuestion(
+	ctx context.Context, sessionId models.SessionId, questionIndex int, deadline time.Time, remaining, added time.Duration,
+) error {
+	fmt.Println("extend question", sessionId, questionIndex, added)
+	if err := datastore.ExtendQuizLock(ctx, sessionId, added); err != nil {
+		return err
+	}
+	if err := datastore.ExtendQuestionWindow(ctx, sessionId, deadline, remaining); err != nil {
+		return err
+	}
+	quizProgressed := &models.QuizProgressedEvent{
+		SessionId:     sessionId,
+		QuestionIndex: questionIndex,
+		EventType:     models.QuestionExtended,
+		Deadline:      deadline,
+		Remaining:     remaining,
+	}
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
+}
@@ -0,0 +1,65 @@
+package managers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"quiz/configs"
+	"quiz/core/models"
+)
+
+var ErrInvalidReconnectToken = errors.New("invalid reconnect token")
+
+// CreateReconnectToken signs the claims of a user joining a quiz session.
+// The token is "{claims}.{signature}", both base64url encoded, the signature is the HMAC-SHA256 of the encoded claims.
+func CreateReconnectToken(
+	sessionId models.SessionId, quizId models.QuizId, username models.Username, expiration time.Duration,
+) (string, error) {
+	claims := &models.ReconnectClaims{
+		SessionId: sessionId,
+		QuizId:    quizId,
+		Username:  username,
+		ExpiresAt: time.Now().Add(expiration).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(sign(encoded)), nil
+}
+
+// ParseReconnectToken verifies the signature and the expiration of the token and returns its claims.
+func ParseReconnectToken(token string) (*models.ReconnectClaims, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found {
+		return nil, ErrInvalidReconnectToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, sign(encoded)) {
+		return nil, ErrInvalidReconnectToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidReconnectToken
+	}
+	claims := &models.ReconnectClaims{}
+	if err = json.Unmarshal(payload, claims); err != nil {
+		return nil, ErrInvalidReconnectToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrInvalidReconnectToken
+	}
+	return claims, nil
+}
+
+func sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, configs.ReconnectSecret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
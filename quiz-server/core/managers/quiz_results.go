@@ -0,0 +1,50 @@
+package managers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"quiz/core/data"
+	"quiz/core/models"
+	"quiz/datastore"
+)
+
+// QuizResults manages the results of the ended quiz sessions, kept after their runtime state expires in Redis.
+type QuizResults struct {
+	resultsRepository data.ResultsRepository
+}
+
+func NewQuizResultsManager(resultsRepository data.ResultsRepository) *QuizResults {
+	return &QuizResults{
+		resultsRepository: resultsRepository,
+	}
+}
+
+// SaveResults copies the final scores and the answers of the session from Redis to the results repository.
+// It can be called again for the same session, eg when the activity ending the quiz is retried.
+func (m *QuizResults) SaveResults(ctx context.Context, sessionId models.SessionId) error {
+	scores, err := datastore.GetFinalScores(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	answers, err := datastore.GetAnswers(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	results := &models.SessionResults{
+		SessionId: sessionId,
+		QuizId:    sessionId.QuizId(),
+		EndedAt:   time.Now(),
+		Players:   models.RankPlayers(scores),
+		Answers:   answers,
+	}
+	if err = m.resultsRepository.SaveResults(ctx, results); err != nil {
+		return fmt.Errorf("error saving session results: %w", err)
+	}
+	return nil
+}
+
+func (m *QuizResults) GetResults(ctx context.Context, sessionId models.SessionId) (*models.SessionResults, error) {
+	return m.resultsRepository.GetResults(ctx, sessionId)
+}
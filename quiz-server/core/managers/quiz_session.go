@@ -4,12 +4,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"sync"
+	"time"
 
 	socketio "github.com/karagenc/socket.io-go"
+	"github.com/samber/lo"
 	"quiz/configs"
 	"quiz/core/data"
 	"quiz/core/models"
+	"quiz/core/questions"
 	"quiz/datastore"
 	"quiz/event_publisher"
 	"quiz/websocket/socket"
@@ -18,12 +22,14 @@ import (
 var mutex sync.Mutex
 
 type QuizSession struct {
-	quizzesInProgress map[models.QuizId]*models.OngoingQuiz
+	sessionsInProgress map[models.SessionId]*models.OngoingQuiz
+	quizRepository     data.QuizRepository
 }
 
-func NewQuizSessionManager() *QuizSession {
+func NewQuizSessionManager(quizRepository data.QuizRepository) *QuizSession {
 	return &QuizSession{
-		quizzesInProgress: make(map[models.QuizId]*models.OngoingQuiz),
+		sessionsInProgress: make(map[models.SessionId]*models.OngoingQuiz),
+		quizRepository:     quizRepository,
 	}
 }
 
@@ -31,168 +37,437 @@ var quizNotFoundError = errors.New("quiz not found")
 
 var QuizInProgressError = errors.New("quiz in progress")
 
-func StartQuiz(ctx context.Context, quizId models.QuizId) error {
-	if err := datastore.MarkQuizAsInProgress(ctx, quizId); err != nil {
+// ErrTimeIsUp rejects the answers to a question which is closed.
+var ErrTimeIsUp = errors.New("time is up")
+
+func StartQuiz(ctx context.Context, sessionId models.SessionId, quizId models.QuizId, lockDuration time.Duration) error {
+	if err := datastore.MarkQuizAsInProgress(ctx, sessionId, quizId, lockDuration); err != nil {
 		if errors.Is(err, datastore.ErrQuizInProgress) {
 			return QuizInProgressError
 		}
 		return err
 	}
 	quizProgressed := &models.QuizProgressedEvent{
-		QuizId:    quizId,
+		SessionId: sessionId,
 		EventType: models.QuizStarted,
 	}
-	return event_publisher.Publish(configs.QuizProgressedTopic, quizId.String(), quizProgressed)
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
 }
 
-func StartNewQuestion(ctx context.Context, quizId models.QuizId, questionIndex int) error {
-	fmt.Println("start new question", quizId, questionIndex)
-	topUsers, err := datastore.GetLeaderboard(ctx, quizId, configs.LeaderboardSize)
+func StartNewQuestion(ctx context.Context, sessionId models.SessionId, questionIndex int, startedAt, deadline time.Time) error {
+	fmt.Println("start new question", sessionId, questionIndex)
+	window := &models.QuestionWindow{QuestionIndex: questionIndex, StartedAt: startedAt, Deadline: deadline}
+	if err := datastore.SaveQuestionWindow(ctx, sessionId, window); err != nil {
+		return err
+	}
+	topUsers, err := datastore.GetLeaderboard(ctx, sessionId, configs.LeaderboardSize)
 	if err != nil {
 		return err
 	}
 	quizProgressed := &models.QuizProgressedEvent{
-		QuizId:        quizId,
+		SessionId:     sessionId,
 		QuestionIndex: questionIndex,
 		Leaderboard:   topUsers,
 		EventType:     models.QuestionStarted,
+		StartedAt:     startedAt,
+		Deadline:      deadline,
 	}
-	if err = event_publisher.Publish(configs.QuizProgressedTopic, quizId.String(), quizProgressed); err != nil {
+	if err = event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed); err != nil {
 		return err
 	}
 	return nil
 }
 
-func EndQuiz(ctx context.Context, quizId models.QuizId) error {
-	fmt.Println("end quiz", quizId)
-	if err := datastore.MarkQuizAsFinished(ctx, quizId); err != nil {
+// CloseQuestion rejects the answers to the question from now on, before its results are revealed.
+// The workflow closes the question once the grace period after its deadline is over, or when the host skips it.
+func CloseQuestion(ctx context.Context, sessionId models.SessionId, questionIndex int) error {
+	fmt.Println("close question", sessionId, questionIndex)
+	if err := datastore.CloseQuestionWindow(ctx, sessionId); err != nil {
+		return err
+	}
+	quizProgressed := &models.QuizProgressedEvent{
+		SessionId:     sessionId,
+		QuestionIndex: questionIndex,
+		EventType:     models.QuestionClosed,
+	}
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
+}
+
+// EndQuestion reveals the correct answer of the question and how the participants answered it.
+func EndQuestion(ctx context.Context, sessionId models.SessionId, questionIndex int, question *models.Question) error {
+	fmt.Println("end question", sessionId, questionIndex)
+	questionType, err := questions.Get(question.Type)
+	if err != nil {
+		return err
+	}
+	results, err := datastore.GetQuestionResults(ctx, sessionId, questionIndex, len(question.Options))
+	if err != nil {
+		return err
+	}
+	questionType.Reveal(question, &results.CorrectAnswer)
+	if results.Answers > 0 {
+		results.PercentCorrect = math.Round(float64(results.CorrectAnswers)/float64(results.Answers)*1000) / 10
+	}
+	quizProgressed := &models.QuizProgressedEvent{
+		SessionId:     sessionId,
+		QuestionIndex: questionIndex,
+		EventType:     models.QuestionEnded,
+		Results:       results,
+	}
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
+}
+
+func EndQuiz(ctx context.Context, sessionId models.SessionId, results *QuizResults) error {
+	fmt.Println("end quiz", sessionId)
+	// the results are saved first, so that a failure is retried while the scores and answers are still in Redis
+	if err := results.SaveResults(ctx, sessionId); err != nil {
+		return err
+	}
+	if err := datastore.MarkQuizAsFinished(ctx, sessionId); err != nil {
 		return err
 	}
-	topUsers, err := datastore.GetLeaderboard(ctx, quizId, configs.LeaderboardSize)
+	topUsers, err := datastore.GetLeaderboard(ctx, sessionId, configs.LeaderboardSize)
 	if err != nil {
 		return err
 	}
-	if err = datastore.CleanUpUserScores(ctx, quizId); err != nil {
+	// the leaderboard is kept a little longer for the players to get their final rank
+	if err = datastore.ExpireUserScores(ctx, sessionId, configs.ScoreRetention); err != nil {
 		return err
 	}
 	quizProgressed := &models.QuizProgressedEvent{
-		QuizId:      quizId,
+		SessionId:   sessionId,
 		EventType:   models.QuizEnded,
 		Leaderboard: topUsers,
 	}
-	return event_publisher.Publish(configs.QuizProgressedTopic, quizId.String(), quizProgressed)
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
 }
 
-func (m *QuizSession) JoinQuiz(ctx context.Context, quizId models.QuizId, username models.Username, socket socketio.ServerSocket) (*models.Quiz, error) {
-	quiz := data.QuizData[quizId]
-	if quiz == nil {
-		return nil, quizNotFoundError
+// AbortReason is sent to the participants when the host aborts the quiz.
+const AbortReason = "the host aborted the quiz"
+
+// AbortQuiz releases the runtime state of a quiz session cancelled before its end,
+// ie the quiz lock, the users in the quiz and the leaderboard, and notifies the participants.
+func AbortQuiz(ctx context.Context, sessionId models.SessionId) error {
+	fmt.Println("abort quiz", sessionId)
+	if err := datastore.MarkQuizAsFinished(ctx, sessionId); err != nil {
+		return err
+	}
+	if err := datastore.ClearParticipants(ctx, sessionId); err != nil {
+		return fmt.Errorf("error clearing participants: %w", err)
 	}
+	topUsers, err := datastore.GetLeaderboard(ctx, sessionId, configs.LeaderboardSize)
+	if err != nil {
+		return err
+	}
+	if err = datastore.CleanUpUserScores(ctx, sessionId); err != nil {
+		return err
+	}
+	quizProgressed := &models.QuizProgressedEvent{
+		SessionId:   sessionId,
+		EventType:   models.QuizAborted,
+		Leaderboard: topUsers,
+		Reason:      AbortReason,
+	}
+	return event_publisher.Publish(configs.QuizProgressedTopic, sessionId.String(), quizProgressed)
+}
 
-	err := datastore.CheckQuizInProgress(ctx, quizId)
+func (m *QuizSession) JoinQuiz(
+	ctx context.Context, sessionId models.SessionId, quizId models.QuizId, username models.Username, socket socketio.ServerSocket,
+) (*models.Quiz, error) {
+	quiz, err := m.quizRepository.GetQuiz(ctx, quizId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = datastore.CheckSessionInProgress(ctx, sessionId)
 	if !errors.Is(err, datastore.ErrQuizInProgress) {
 		fmt.Println("check quiz in progress error", err)
 		return nil, errors.New("quiz haven't been started")
 	}
 
-	if err = datastore.MarkUserAsInQuiz(ctx, quizId, username); err != nil {
+	if err = datastore.MarkUserAsInQuiz(ctx, sessionId, username, string(socket.ID()), quiz.LockDuration()); err != nil {
 		return nil, err
 	}
+	m.bindSocket(sessionId, quiz, username, socket, nil)
+
+	publishParticipantChanged(ctx, sessionId, username, true)
+	return questions.RedactQuiz(quiz), nil
+}
 
+// LeaveQuiz removes the user of the disconnected socket from the participants of its quiz session.
+// Disconnecting is not leaving the quiz: the username stays taken, and the user can resume the session
+// with the reconnect token received when joining.
+func (m *QuizSession) LeaveQuiz(ctx context.Context, s socketio.ServerSocket) {
 	mutex.Lock()
-	ongoingQuiz := m.quizzesInProgress[quizId]
+	var sessionId models.SessionId
+	var username models.Username = ""
+	for id, ongoingQuiz := range m.sessionsInProgress {
+		for k, v := range ongoingQuiz.Participants {
+			if v.Socket == s {
+				sessionId, username = id, k
+				delete(ongoingQuiz.Participants, k)
+			}
+		}
+	}
+	mutex.Unlock()
+	if username == "" {
+		return
+	}
+	// the user may have resumed the session with another socket before this one is known to be disconnected
+	removed, err := datastore.RemoveParticipant(ctx, sessionId, username, string(s.ID()))
+	if err != nil {
+		fmt.Println("error removing participant", err)
+		return
+	}
+	if removed {
+		publishParticipantChanged(ctx, sessionId, username, false)
+	}
+}
+
+// bindSocket binds the socket of the user to the quiz session on this instance, creating the session if this instance
+// has not seen it start. The state of a created session is taken from the answer window of its current question, if any.
+func (m *QuizSession) bindSocket(
+	sessionId models.SessionId, quiz *models.Quiz, username models.Username, socket socketio.ServerSocket,
+	window *models.QuestionWindow,
+) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	ongoingQuiz := m.sessionsInProgress[sessionId]
 	if ongoingQuiz == nil {
 		ongoingQuiz = &models.OngoingQuiz{
-			Id:                   quiz.Id,
+			SessionId:            sessionId,
 			CurrentQuestionIndex: -1,
 			Participants:         map[models.Username]*models.UserSession{},
 		}
-		m.quizzesInProgress[quizId] = ongoingQuiz
+		if window != nil {
+			ongoingQuiz.CurrentQuestionIndex = window.QuestionIndex
+			ongoingQuiz.QuestionStartedAt = window.StartedAt
+			ongoingQuiz.QuestionDeadline = window.Deadline
+			ongoingQuiz.Paused = window.Paused
+			ongoingQuiz.PausedRemaining = window.Remaining
+		}
+		m.sessionsInProgress[sessionId] = ongoingQuiz
 	}
-	ongoingQuiz.Participants[username] = &models.UserSession{
-		Socket:            socket,
-		AnsweredQuestions: map[int]bool{},
+	if ongoingQuiz.Quiz == nil {
+		ongoingQuiz.Quiz = quiz
+	}
+	ongoingQuiz.Participants[username] = &models.UserSession{Socket: socket}
+}
+
+// ResumeSession binds the socket of a reconnected user to the quiz session given by the reconnect token,
+// possibly on another instance than the one the user joined, and returns the quiz and the state of the session.
+// The state is read from Redis, as the instance may not have consumed the events of the session.
+func (m *QuizSession) ResumeSession(
+	ctx context.Context, claims *models.ReconnectClaims, socket socketio.ServerSocket,
+) (*models.Quiz, *models.ResumeSessionResult, error) {
+	sessionId, username := claims.SessionId, claims.Username
+	if err := datastore.CheckUserInQuiz(ctx, sessionId, username); err != nil {
+		return nil, nil, err
+	}
+	quiz, err := m.quizRepository.GetQuiz(ctx, claims.QuizId)
+	if err != nil {
+		return nil, nil, err
+	}
+	score, err := datastore.GetUserScore(ctx, sessionId, username)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting user score: %w", err)
+	}
+	streak, err := datastore.GetUserStreak(ctx, sessionId, username)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting user streak: %w", err)
+	}
+
+	window, err := datastore.GetQuestionWindow(ctx, sessionId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting question window: %w", err)
 	}
-	mutex.Unlock()
 
-	return quiz.FilterAnswers(), nil
+	result := &models.ResumeSessionResult{
+		SessionId:     sessionId,
+		Username:      username,
+		QuestionIndex: -1, // in the lobby until the first question starts
+		Score:         score,
+		Streak:        streak,
+	}
+	if window != nil {
+		result.QuestionIndex = window.QuestionIndex
+		result.Paused = window.Paused
+		result.StartedAt = window.StartedAt.UnixMilli()
+		result.Deadline = window.Deadline.UnixMilli()
+		switch {
+		case window.Paused:
+			result.TimeRemainingMs = window.Remaining.Milliseconds()
+		case !window.Closed:
+			result.TimeRemainingMs = max(0, time.Until(window.Deadline).Milliseconds())
+		}
+		if result.Answered, err = datastore.HasAnswered(ctx, sessionId, username, result.QuestionIndex); err != nil {
+			return nil, nil, fmt.Errorf("error getting user answer: %w", err)
+		}
+	}
+	if err = datastore.AddParticipant(ctx, sessionId, username, string(socket.ID()), quiz.LockDuration()); err != nil {
+		return nil, nil, err
+	}
+	m.bindSocket(sessionId, quiz, username, socket, window)
+	publishParticipantChanged(ctx, sessionId, username, true)
+	return questions.RedactQuiz(quiz), result, nil
 }
 
-func (m *QuizSession) AnswerQuestion(
-	s socketio.ServerSocket, quizId models.QuizId, questionIndex, answerIndex int,
-) (*models.AnswerQuestionResult, error) {
-	ongoingQuiz := m.quizzesInProgress[quizId]
-	if ongoingQuiz == nil {
-		return nil, errors.New("quiz haven't been started")
+func (m *QuizSession) GetParticipants(ctx context.Context, sessionId models.SessionId) ([]models.Username, error) {
+	return datastore.GetParticipants(ctx, sessionId)
+}
+
+// publishParticipantChanged notifies all the instances that a user joined or left, with the current participants.
+func publishParticipantChanged(ctx context.Context, sessionId models.SessionId, username models.Username, joined bool) {
+	participants, err := datastore.GetParticipants(ctx, sessionId)
+	if err != nil {
+		fmt.Println("error getting participants", err)
+		return
 	}
+	event := &models.ParticipantChangedEvent{
+		SessionId:    sessionId,
+		Username:     username,
+		Joined:       joined,
+		Participants: participants,
+	}
+	if err = event_publisher.Publish(configs.ParticipantChangedTopic, sessionId.String(), event); err != nil {
+		fmt.Println("error publishing participant changed event", err)
+	}
+}
 
+func (m *QuizSession) AnswerQuestion(
+	s socketio.ServerSocket, answer *models.QuestionAnsweredPayload,
+) (*models.AnswerQuestionResult, error) {
+	answeredAt := time.Now()
+	sessionId, questionIndex := answer.SessionId, answer.QuestionIndex
+	mutex.Lock()
+	ongoingQuiz := m.sessionsInProgress[sessionId]
+	var quiz *models.Quiz
 	var username models.Username = ""
-	for k, v := range ongoingQuiz.Participants {
-		if v.Socket == s {
-			username = k
+	if ongoingQuiz != nil {
+		quiz = ongoingQuiz.Quiz
+		for k, v := range ongoingQuiz.Participants {
+			if v.Socket == s {
+				username = k
+			}
 		}
 	}
+	mutex.Unlock()
+	if ongoingQuiz == nil {
+		return nil, errors.New("quiz haven't been started")
+	}
 	if username == "" {
 		return nil, errors.New("user hasn't connected")
 	}
-
-	quiz := m.quizzesInProgress[quizId]
 	if quiz == nil {
 		return nil, quizNotFoundError
 	}
-	if quiz.CurrentQuestionIndex != questionIndex {
-		return nil, fmt.Errorf("question is not in progress: %d, %d", quiz.CurrentQuestionIndex, questionIndex)
+	ctx := context.Background()
+	// the answer window is read from Redis rather than from the events consumed by this instance,
+	// so that an answer is scored the same way whatever the consumer lag of the instance.
+	// Whether the answer is too late or the quiz paused is decided when recording it, with the Redis clock.
+	window, err := datastore.GetQuestionWindow(ctx, sessionId)
+	if err != nil {
+		return nil, err
 	}
-
-	session := quiz.Participants[username]
-	if session == nil {
-		return nil, fmt.Errorf("user does not exist")
+	if window == nil || window.QuestionIndex < questionIndex {
+		return nil, fmt.Errorf("question is not in progress: %d", questionIndex)
 	}
 
-	session.Mutex.Lock()
-	if session.AnsweredQuestions[questionIndex] {
-		session.Mutex.Unlock()
-		return nil, fmt.Errorf("question already answered")
+	if questionIndex < 0 || questionIndex >= len(quiz.Questions) {
+		return nil, fmt.Errorf("invalid question index: %d", questionIndex)
 	}
-	session.AnsweredQuestions[questionIndex] = true
-	session.Mutex.Unlock()
-
-	question := data.QuizData[quiz.Id].Questions[questionIndex]
-	dScore := 0
-	if answerIndex == question.CorrectAnswerIndex {
-		dScore = 1
+	question := &quiz.Questions[questionIndex]
+	questionType, err := questions.Get(question.Type)
+	if err != nil {
+		return nil, err
 	}
-	ctx := context.Background()
-	newScore, err := datastore.AddOrUpdateUserScore(ctx, quizId, username, dScore)
+	credit, err := questionType.Check(quiz, question, &answer.Answer)
 	if err != nil {
-		return nil, fmt.Errorf("error adding new quiz score: %w", err)
+		return nil, err
 	}
-	var leaderboard []models.UserScore
+
+	timing := &models.AnswerTiming{
+		StartedAt:  window.StartedAt,
+		Deadline:   window.Deadline,
+		AnsweredAt: answeredAt,
+	}
+	score := questionType.Score(quiz, question, credit, timing)
+	// the streak is only known when the answer is recorded, so the points are given for each possible streak
+	pointsByStreak := make([]models.Score, questionIndex+2)
+	for streak := range pointsByStreak {
+		pointsByStreak[streak] = questions.StreakScore(quiz, score, streak)
+	}
+	// only fully correct answers extend the streak
+	input := &models.SubmittedAnswerInput{
+		AnsweredAt:     answeredAt,
+		ResponseTime:   answeredAt.Sub(window.StartedAt),
+		Correct:        credit >= 1,
+		Selected:       questionType.Selected(question, &answer.Answer),
+		PointsByStreak: pointsByStreak,
+	}
+	submitted, err := datastore.SubmitAnswer(ctx, sessionId, username, questionIndex, input,
+		configs.LeaderboardSize, quiz.LockDuration())
+	if err != nil {
+		switch {
+		case errors.Is(err, datastore.ErrAnswerTooLate):
+			return nil, ErrTimeIsUp
+		case errors.Is(err, datastore.ErrQuestionAnswered), errors.Is(err, datastore.ErrQuizPaused),
+			errors.Is(err, datastore.ErrQuestionNotInProgress):
+			return nil, err
+		}
+		return nil, fmt.Errorf("error submitting answer: %w", err)
+	}
+	dScore := pointsByStreak[submitted.Streak]
 	if dScore > 0 {
-		leaderboard, err = datastore.GetLeaderboard(ctx, quizId, configs.LeaderboardSize)
 		event := &models.ScoreUpdatedEvent{
-			QuizId:      quizId,
+			SessionId:   sessionId,
 			Username:    username,
-			Leaderboard: leaderboard,
+			Leaderboard: submitted.Leaderboard,
 		}
-		if err = event_publisher.Publish(configs.ScoreUpdatedTopic, quizId.String(), event); err != nil {
+		if err = event_publisher.Publish(configs.ScoreUpdatedTopic, sessionId.String(), event); err != nil {
 			fmt.Println("error publishing quiz score updated event", err)
 		}
 	}
-	return &models.AnswerQuestionResult{
-		CorrectAnswerIndex: question.CorrectAnswerIndex,
-		NewScore:           models.Score(newScore),
-		Leaderboard:        leaderboard,
-	}, nil
+	result := &models.AnswerQuestionResult{
+		Points:      dScore,
+		Streak:      submitted.Streak,
+		NewScore:    submitted.NewScore,
+		Rank:        submitted.Rank,
+		Leaderboard: submitted.Leaderboard,
+	}
+	questionType.Reveal(question, &result.CorrectAnswer)
+	return result, nil
+}
+
+// getOngoingQuiz returns the session running on this instance, nil if none.
+// The sessions and their fields are shared by the socket and the event handlers, and only accessed under the mutex.
+func (m *QuizSession) getOngoingQuiz(sessionId models.SessionId) *models.OngoingQuiz {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return m.sessionsInProgress[sessionId]
 }
 
 func (m *QuizSession) OnScoreUpdated(event *models.ScoreUpdatedEvent) error {
-	ongoingQuiz := m.quizzesInProgress[event.QuizId]
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
+	if ongoingQuiz == nil {
+		fmt.Println("quiz haven't been started")
+		return nil
+	}
+	socket.NotifyScoreUpdated(event.SessionId, event.Username, event.Leaderboard)
+	return nil
+}
+
+func (m *QuizSession) OnParticipantChanged(event *models.ParticipantChangedEvent) error {
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
 	if ongoingQuiz == nil {
 		fmt.Println("quiz haven't been started")
 		return nil
 	}
-	socket.NotifyScoreUpdated(event.QuizId, event.Username, event.Leaderboard)
+	if event.Joined {
+		socket.NotifyPlayerJoined(event.SessionId, event.Username, event.Participants)
+	} else {
+		socket.NotifyPlayerLeft(event.SessionId, event.Username, event.Participants)
+	}
 	return nil
 }
 
@@ -204,6 +479,18 @@ func (m *QuizSession) OnQuizProgressed(event *models.QuizProgressedEvent) error
 		return m.onQuestionStarted(event)
 	case models.QuizEnded:
 		return m.onQuizEnded(event)
+	case models.QuizPaused:
+		return m.onQuizPaused(event)
+	case models.QuizResumed:
+		return m.onQuizResumed(event)
+	case models.QuestionExtended:
+		return m.onQuestionExtended(event)
+	case models.QuizAborted:
+		return m.onQuizAborted(event)
+	case models.QuestionClosed:
+		return m.onQuestionClosed(event)
+	case models.QuestionEnded:
+		return m.onQuestionEnded(event)
 	default:
 		fmt.Println("unknown quiz event")
 		return nil
@@ -211,48 +498,151 @@ func (m *QuizSession) OnQuizProgressed(event *models.QuizProgressedEvent) error
 }
 
 func (m *QuizSession) onQuizStarted(event *models.QuizProgressedEvent) error {
-	ongoingQuiz := m.quizzesInProgress[event.QuizId]
-	if ongoingQuiz != nil {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if m.sessionsInProgress[event.SessionId] != nil {
 		fmt.Println("quiz has already been started")
 		return nil
 	}
-	ongoingQuiz = &models.OngoingQuiz{
-		Id:                   event.QuizId,
+	m.sessionsInProgress[event.SessionId] = &models.OngoingQuiz{
+		SessionId:            event.SessionId,
 		Participants:         map[models.Username]*models.UserSession{},
 		CurrentQuestionIndex: -1, // for pending period
 	}
-	// handle race condition
-	mutex.Lock()
-	m.quizzesInProgress[event.QuizId] = ongoingQuiz
-	mutex.Unlock()
 	return nil
 }
 
 func (m *QuizSession) onQuestionStarted(event *models.QuizProgressedEvent) error {
-	ongoingQuiz := m.quizzesInProgress[event.QuizId]
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
 	if ongoingQuiz == nil {
 		fmt.Println("quiz hasn't been started")
 		return nil
 	}
+	mutex.Lock()
 	ongoingQuiz.CurrentQuestionIndex = event.QuestionIndex
-	socket.NotifyQuestionEnded(ongoingQuiz.Id, ongoingQuiz.CurrentQuestionIndex, event.Leaderboard)
+	ongoingQuiz.QuestionStartedAt = event.StartedAt
+	ongoingQuiz.QuestionDeadline = event.Deadline
+	ongoingQuiz.Paused = false // the host can skip a paused question
+	mutex.Unlock()
+	socket.NotifyQuestionStarted(event.SessionId, event.QuestionIndex, event.Leaderboard, event.StartedAt, event.Deadline)
+	notifyRanks(ongoingQuiz)
+	return nil
+}
+
+// notifyRanks sends each player connected to this instance its own rank.
+func notifyRanks(ongoingQuiz *models.OngoingQuiz) {
+	mutex.Lock()
+	sockets := make(map[models.Username]socketio.ServerSocket, len(ongoingQuiz.Participants))
+	for username, session := range ongoingQuiz.Participants {
+		sockets[username] = session.Socket
+	}
+	mutex.Unlock()
+	if len(sockets) == 0 {
+		return
+	}
+	ranks, err := datastore.GetPlayerRanks(context.Background(), ongoingQuiz.SessionId, lo.Keys(sockets))
+	if err != nil {
+		fmt.Println("error getting player ranks", err)
+		return
+	}
+	for username, rank := range ranks {
+		socket.NotifyMyRank(sockets[username], rank)
+	}
+}
+
+func (m *QuizSession) onQuestionClosed(event *models.QuizProgressedEvent) error {
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
+	if ongoingQuiz == nil {
+		fmt.Println("quiz hasn't been started")
+		return nil
+	}
+	socket.NotifyQuestionClosed(event.SessionId, event.QuestionIndex)
+	return nil
+}
+
+func (m *QuizSession) onQuestionEnded(event *models.QuizProgressedEvent) error {
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
+	if ongoingQuiz == nil {
+		fmt.Println("quiz hasn't been started")
+		return nil
+	}
+	socket.NotifyQuestionEnded(event.SessionId, event.Results)
 	return nil
 }
 
 func (m *QuizSession) onQuizEnded(event *models.QuizProgressedEvent) error {
-	ongoingQuiz := m.quizzesInProgress[event.QuizId]
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
 	if ongoingQuiz == nil {
 		fmt.Println("quiz haven't been started")
 		return nil
 	}
-	for username := range ongoingQuiz.Participants {
-		if err := datastore.MarkUserAsNotInQuiz(context.Background(), event.QuizId, username); err != nil {
+	mutex.Lock()
+	usernames := lo.Keys(ongoingQuiz.Participants)
+	delete(m.sessionsInProgress, event.SessionId)
+	mutex.Unlock()
+	for _, username := range usernames {
+		if err := datastore.MarkUserAsNotInQuiz(context.Background(), event.SessionId, username); err != nil {
 			fmt.Println("error marking quiz as not-in-quiz")
 		}
 	}
+	socket.NotifyQuizEnded(event.SessionId, event.Leaderboard)
+	notifyRanks(ongoingQuiz)
+	return nil
+}
+
+func (m *QuizSession) onQuizPaused(event *models.QuizProgressedEvent) error {
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
+	if ongoingQuiz == nil {
+		fmt.Println("quiz hasn't been started")
+		return nil
+	}
+	mutex.Lock()
+	ongoingQuiz.Paused = true
+	ongoingQuiz.PausedRemaining = event.Remaining
+	mutex.Unlock()
+	socket.NotifyQuizPaused(event.SessionId, event.QuestionIndex, event.Remaining)
+	return nil
+}
+
+func (m *QuizSession) onQuizResumed(event *models.QuizProgressedEvent) error {
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
+	if ongoingQuiz == nil {
+		fmt.Println("quiz hasn't been started")
+		return nil
+	}
+	mutex.Lock()
+	ongoingQuiz.Paused = false
+	ongoingQuiz.QuestionStartedAt = event.StartedAt
+	ongoingQuiz.QuestionDeadline = event.Deadline
+	mutex.Unlock()
+	socket.NotifyQuizResumed(event.SessionId, event.QuestionIndex, event.StartedAt, event.Deadline)
+	return nil
+}
+
+func (m *QuizSession) onQuestionExtended(event *models.QuizProgressedEvent) error {
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
+	if ongoingQuiz == nil {
+		fmt.Println("quiz hasn't been started")
+		return nil
+	}
+	mutex.Lock()
+	ongoingQuiz.QuestionDeadline = event.Deadline
+	ongoingQuiz.PausedRemaining = event.Remaining
+	mutex.Unlock()
+	socket.NotifyQuestionExtended(event.SessionId, event.QuestionIndex, event.Deadline, event.Remaining)
+	return nil
+}
+
+func (m *QuizSession) onQuizAborted(event *models.QuizProgressedEvent) error {
+	ongoingQuiz := m.getOngoingQuiz(event.SessionId)
+	if ongoingQuiz == nil {
+		fmt.Println("quiz haven't been started")
+		return nil
+	}
+	// the users in the quiz have been cleared by the abort activity
 	mutex.Lock()
-	delete(m.quizzesInProgress, event.QuizId)
+	delete(m.sessionsInProgress, event.SessionId)
 	mutex.Unlock()
-	socket.NotifyQuizEnded(event.QuizId, event.Leaderboard)
+	socket.NotifyQuizAborted(event.SessionId, event.Reason, event.Leaderboard)
 	return nil
 }
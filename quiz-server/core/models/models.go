@@ -1,51 +1,218 @@
 package models
 
 import (
+	"errors"
 	"fmt"
-	"sync"
+	"time"
 
 	socketio "github.com/karagenc/socket.io-go"
+	"quiz/configs"
 )
 
 type Quiz struct {
 	Id        QuizId     `json:"id"`
 	Questions []Question `json:"questions"`
+	// PartialCredit gives a proportional score to partially correct answers of multiple-choice questions,
+	// instead of scoring them all-or-nothing
+	PartialCredit bool `json:"partial_credit,omitempty"`
+	// Scoring overrides the default speed-weighted scoring, see configs.MaxQuestionScore
+	Scoring *Scoring `json:"scoring,omitempty"`
+	// StartMode selects how the session leaves the lobby: after the lobby duration (timer, the default),
+	// or when the host begins it (host), in which case the lobby duration is the maximum lobby time
+	StartMode StartMode `json:"start_mode,omitempty"`
+	// AutoStartPlayers begins a quiz in host mode once this number of participants joined
+	AutoStartPlayers int `json:"auto_start_players,omitempty"`
+	// LobbySeconds is the time participants have to join before the first question, see configs.DefaultLobbyTime
+	LobbySeconds int `json:"lobby_seconds,omitempty"`
+	// QuestionSeconds is the default time limit of the questions, see configs.DefaultQuestionTime
+	QuestionSeconds int `json:"question_seconds,omitempty"`
+	// RevealSeconds is the time the results of a question are shown before the next one, see configs.DefaultRevealTime
+	RevealSeconds int `json:"reveal_seconds,omitempty"`
+}
+
+// Scoring configures the score of a correct answer, which decreases linearly
+// from MaxScore when the question starts to MinScore at the question deadline.
+// Setting both to the same value scores all correct answers equally.
+// Each consecutive correct answer after the first adds StreakBonus to the score multiplier,
+// up to MaxStreakBonus, eg 0.1 and 0.5 make the 3rd correct answer in a row worth 120%.
+type Scoring struct {
+	MaxScore       Score   `json:"max_score"`
+	MinScore       Score   `json:"min_score"`
+	StreakBonus    float64 `json:"streak_bonus,omitempty"`
+	MaxStreakBonus float64 `json:"max_streak_bonus,omitempty"`
+}
+
+// LobbyDuration is the time between the start of the quiz and its first question.
+func (q *Quiz) LobbyDuration() time.Duration {
+	if q.LobbySeconds > 0 {
+		return time.Duration(q.LobbySeconds) * time.Second
+	}
+	if q.StartMode == HostStart {
+		return configs.MaxLobbyTime
+	}
+	return configs.DefaultLobbyTime
+}
+
+// QuestionDuration is the time limit of the question at the given index.
+func (q *Quiz) QuestionDuration(index int) time.Duration {
+	if seconds := q.Questions[index].TimeLimitSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if q.QuestionSeconds > 0 {
+		return time.Duration(q.QuestionSeconds) * time.Second
+	}
+	return configs.DefaultQuestionTime
+}
+
+// RevealDuration is the time between the end of a question and the start of the next one.
+func (q *Quiz) RevealDuration() time.Duration {
+	if q.RevealSeconds > 0 {
+		return time.Duration(q.RevealSeconds) * time.Second
+	}
+	return configs.DefaultRevealTime
+}
+
+// Duration is the total time of a quiz session, from the start of the lobby to the end of the last reveal.
+func (q *Quiz) Duration() time.Duration {
+	duration := q.LobbyDuration()
+	for i := range q.Questions {
+		duration += q.QuestionDuration(i) + q.RevealDuration()
+	}
+	return duration
+}
+
+// LockDuration is the expiration of the runtime state of a quiz session in Redis,
+// so that it is released even if the session doesn't end properly.
+func (q *Quiz) LockDuration() time.Duration {
+	return q.Duration() + configs.QuizLockMargin
 }
 
 type Question struct {
-	Content            string `json:"content"`
-	CorrectAnswerIndex int    `json:"correct_answer_index"`
+	// Type selects the implementation in the questions package, eg validation and answer checking
+	Type               QuestionType `json:"type,omitempty"`
+	Content            string       `json:"content"`
+	Options            []Option     `json:"options"`
+	CorrectAnswerIndex int          `json:"correct_answer_index"`
+	// CorrectAnswerIndices is the set of correct options of a multiple-choice question
+	CorrectAnswerIndices []int `json:"correct_answer_indices,omitempty"`
+	// AcceptedAnswers are the correct answers of a text question, the first one is the canonical spelling
+	AcceptedAnswers []string      `json:"accepted_answers,omitempty"`
+	Matching        *TextMatching `json:"matching,omitempty"`
+	// TimeLimitSeconds overrides the question time limit of the quiz
+	TimeLimitSeconds int `json:"time_limit_seconds,omitempty"`
+}
+
+// TextMatching configures how a typed answer is compared to the accepted answers.
+// By default the comparison ignores case, diacritics and extra whitespace, and allows no typos.
+type TextMatching struct {
+	CaseSensitive  bool `json:"case_sensitive,omitempty"`
+	KeepDiacritics bool `json:"keep_diacritics,omitempty"`
+	KeepWhitespace bool `json:"keep_whitespace,omitempty"`
+	// MaxTypos is the maximum Levenshtein distance between the answer and an accepted answer
+	MaxTypos int `json:"max_typos,omitempty"`
+}
+
+// Option is an answer choice of a question. Its ID is stable across edits of the quiz,
+// so clients can submit answers by option ID.
+type Option struct {
+	Id       string `json:"id"`
+	Text     string `json:"text"`
+	MediaUrl string `json:"media_url,omitempty"`
 }
 
 type OngoingQuiz struct {
-	Id                   QuizId
+	SessionId            SessionId
+	Quiz                 *Quiz // loaded from the quiz repository when the first user joins on this instance
 	Participants         map[Username]*UserSession
 	CurrentQuestionIndex int
+	QuestionStartedAt    time.Time
+	QuestionDeadline     time.Time
+	Paused               bool          // the countdown is stopped while the host pauses the quiz
+	PausedRemaining      time.Duration // answer time left while the quiz is paused
 }
 
 type UserSession struct {
-	Socket            socketio.ServerSocket
-	AnsweredQuestions map[int]bool
-	Mutex             sync.Mutex
+	Socket socketio.ServerSocket
 }
 
 type QuizProgressedEvent struct {
-	QuizId        QuizId      `json:"quiz_id"`
+	SessionId     SessionId   `json:"session_id"`
 	QuestionIndex int         `json:"question_index"`
 	EventType     EventType   `json:"event_type"`
 	Leaderboard   []UserScore `json:"leaderboard"`
+	// StartedAt and Deadline delimit the answer window of a started question
+	StartedAt time.Time `json:"started_at"`
+	Deadline  time.Time `json:"deadline"`
+	// Remaining is the answer time left when the quiz is paused or the question extended
+	Remaining time.Duration `json:"remaining"`
+	// Reason explains why the quiz was aborted
+	Reason string `json:"reason,omitempty"`
+	// Results is the answer distribution of an ended question
+	Results *QuestionResults `json:"results,omitempty"`
+}
+
+// SessionPin is the quiz session that a PIN gives access to.
+type SessionPin struct {
+	SessionId SessionId `json:"session_id"`
+	QuizId    QuizId    `json:"quiz_id"`
+}
+
+// ReconnectClaims are signed in the reconnect token given to a user joining a quiz session,
+// which lets the user resume the session from another socket after a disconnection.
+type ReconnectClaims struct {
+	SessionId SessionId `json:"session_id"`
+	QuizId    QuizId    `json:"quiz_id"`
+	Username  Username  `json:"username"`
+	ExpiresAt int64     `json:"exp"` // unix seconds
+}
+
+// ResumeSessionResult is the state of the quiz session sent to a user resuming it,
+// the times are unix milliseconds.
+type ResumeSessionResult struct {
+	SessionId     SessionId `json:"session_id"`
+	Username      Username  `json:"username"`
+	QuestionIndex int       `json:"question_index"` // -1 in the lobby
+	// Answered is true if the user has already answered the current question
+	Answered        bool  `json:"answered"`
+	Score           Score `json:"score"`
+	Streak          int   `json:"streak"`
+	Paused          bool  `json:"paused"`
+	StartedAt       int64 `json:"started_at"`
+	Deadline        int64 `json:"deadline"`
+	TimeRemainingMs int64 `json:"time_remaining_ms"`
+}
+
+// ParticipantChangedEvent notifies that a user joined or left the quiz session, with the current participants.
+type ParticipantChangedEvent struct {
+	SessionId    SessionId  `json:"session_id"`
+	Username     Username   `json:"username"`
+	Joined       bool       `json:"joined"`
+	Participants []Username `json:"participants"`
 }
 
 type ScoreUpdatedEvent struct {
-	QuizId      QuizId      `json:"quiz_id"`
+	SessionId   SessionId   `json:"session_id"`
 	Username    Username    `json:"username"`
 	Leaderboard []UserScore `json:"leaderboard"`
 }
 
 type QuestionAnsweredPayload struct {
-	QuizId        QuizId `json:"quiz_id"`
-	QuestionIndex int    `json:"question_index"`
-	AnswerIndex   int    `json:"answer_index"`
+	SessionId     SessionId `json:"session_id"`
+	QuestionIndex int       `json:"question_index"`
+	Answer
+}
+
+// Answer holds the answer to a question, the fields used depend on the question type.
+type Answer struct {
+	AnswerIndex int `json:"answer_index"`
+	// OptionId takes precedence over AnswerIndex if set
+	OptionId string `json:"option_id,omitempty"`
+	// AnswerIndices and OptionIds are the selected options of a multiple-choice question,
+	// OptionIds takes precedence over AnswerIndices if set
+	AnswerIndices []int    `json:"answer_indices,omitempty"`
+	OptionIds     []string `json:"option_ids,omitempty"`
+	// TextAnswer is the typed answer of a text question
+	TextAnswer string `json:"text_answer,omitempty"`
 }
 
 type UserScore struct {
@@ -53,37 +220,208 @@ type UserScore struct {
 	Score    Score    `json:"score"`
 }
 
+// CorrectAnswer is the correct answer of a question, the fields set depend on the question type.
+type CorrectAnswer struct {
+	CorrectAnswerIndex   int      `json:"correct_answer_index"`
+	CorrectOptionId      string   `json:"correct_option_id,omitempty"`
+	CorrectAnswerIndices []int    `json:"correct_answer_indices,omitempty"`
+	CorrectOptionIds     []string `json:"correct_option_ids,omitempty"`
+	CanonicalAnswer      string   `json:"canonical_answer,omitempty"`
+}
+
 type AnswerQuestionResult struct {
-	CorrectAnswerIndex int
-	NewScore           Score
-	Leaderboard        []UserScore
+	CorrectAnswer
+	Points      Score       `json:"points"`
+	Streak      int         `json:"streak"`
+	NewScore    Score       `json:"new_score"`
+	Rank        int64       `json:"rank"`
+	Leaderboard []UserScore `json:"leaderboard"`
+}
+
+// QuestionResults is how the participants answered a question, revealed when the question ends.
+type QuestionResults struct {
+	QuestionIndex int `json:"question_index"`
+	CorrectAnswer
+	// Histogram is the number of answers selecting each option, empty for a text question
+	Histogram      []int64 `json:"histogram"`
+	Answers        int64   `json:"answers"`
+	CorrectAnswers int64   `json:"correct_answers"`
+	// PercentCorrect is the share of the answers which are fully correct, between 0 and 100
+	PercentCorrect float64        `json:"percent_correct"`
+	Fastest        *FastestAnswer `json:"fastest,omitempty"`
+}
+
+// FastestAnswer is the first fully correct answer to a question.
+type FastestAnswer struct {
+	Username       Username `json:"username"`
+	ResponseTimeMs int64    `json:"response_time_ms"`
+}
+
+// PlayerRank is the position of a player in the leaderboard, with the players immediately above and below.
+type PlayerRank struct {
+	Rank         int64      `json:"rank"`
+	Score        Score      `json:"score"`
+	TotalPlayers int64      `json:"total_players"`
+	Above        *UserScore `json:"above,omitempty"`
+	Below        *UserScore `json:"below,omitempty"`
+}
+
+// SubmittedAnswerInput is an answer checked and scored, to be recorded.
+type SubmittedAnswerInput struct {
+	AnsweredAt   time.Time
+	ResponseTime time.Duration // since the question started
+	// Correct is true if the answer is fully correct, which extends the streak
+	Correct  bool
+	Selected []int // indices of the selected options
+	// PointsByStreak are the points of the answer by the resulting streak
+	PointsByStreak []Score
+}
+
+// QuestionWindow is the answer window of the current question of a session, shared by all the instances
+// so that answers are accepted or rejected the same way whichever instance receives them.
+type QuestionWindow struct {
+	QuestionIndex int
+	StartedAt     time.Time
+	Deadline      time.Time
+	Paused        bool
+	Closed        bool          // closed after the grace period of the deadline, or before when the host skips the question
+	Remaining     time.Duration // answer time left while paused
+}
+
+// SessionResults are the final results of an ended quiz session, kept by the results repository
+// after the runtime state of the session expires.
+type SessionResults struct {
+	SessionId SessionId      `json:"session_id"`
+	QuizId    QuizId         `json:"quiz_id"`
+	EndedAt   time.Time      `json:"ended_at"`
+	Players   []PlayerResult `json:"players"`
+	Answers   []AnswerResult `json:"answers"`
+}
+
+// PlayerResult is the final score and rank of a participant, from 1.
+type PlayerResult struct {
+	Username Username `json:"username"`
+	Rank     int64    `json:"rank"`
+	Score    Score    `json:"score"`
+}
+
+// RankPlayers ranks the scores sorted from the highest. Equal scores share the same rank,
+// and the following ranks are skipped (1, 1, 3).
+func RankPlayers(scores []UserScore) []PlayerResult {
+	players := make([]PlayerResult, len(scores))
+	for i, score := range scores {
+		rank := int64(i + 1)
+		if i > 0 && score.Score == scores[i-1].Score {
+			rank = players[i-1].Rank
+		}
+		players[i] = PlayerResult{
+			Username: score.Username,
+			Rank:     rank,
+			Score:    score.Score,
+		}
+	}
+	return players
+}
+
+// AnswerResult is the answer of a participant to a question, as scored.
+type AnswerResult struct {
+	QuestionIndex  int       `json:"question_index"`
+	Username       Username  `json:"username"`
+	AnsweredAt     time.Time `json:"answered_at"`
+	ResponseTimeMs int64     `json:"response_time_ms"`
+	Correct        bool      `json:"correct"`
+	Points         Score     `json:"points"`
+	Selected       []int     `json:"selected,omitempty"`
+}
+
+// SubmittedAnswer is the state of the user and the leaderboard right after an answer is scored.
+type SubmittedAnswer struct {
+	Streak      int
+	NewScore    Score
+	Rank        int64
+	Leaderboard []UserScore
+}
+
+// AnswerTiming places an answer within the answer window of the question.
+type AnswerTiming struct {
+	StartedAt  time.Time
+	Deadline   time.Time
+	AnsweredAt time.Time
 }
 
 type Username string
 
-type Score int
+type Score float64
 
 type QuizId int
 
+// SessionId identifies a run of a quiz, which all the runtime state is keyed on,
+// so that a quiz can be played by several groups concurrently.
+type SessionId string
+
 type EventType int
 
+type QuestionType string
+
+const (
+	SingleChoice   QuestionType = "single_choice" // default if the type is empty
+	MultipleChoice QuestionType = "multiple_choice"
+	Text           QuestionType = "text"
+)
+
 const (
 	QuizStarted EventType = iota + 1
 	QuestionStarted
 	QuizEnded
+	QuizPaused
+	QuizResumed
+	QuestionExtended
+	QuizAborted
+	QuestionEnded
+	QuestionClosed
 )
 
-func (q *Quiz) FilterAnswers() *Quiz {
-	res := &Quiz{
-		Id: q.Id,
-	}
-	for _, question := range q.Questions {
-		res.Questions = append(res.Questions, Question{
-			Content:            question.Content,
-			CorrectAnswerIndex: -1,
-		})
+type StartMode string
+
+const (
+	TimerStart StartMode = "timer" // default if the start mode is empty
+	HostStart  StartMode = "host"
+)
+
+type SessionPhase string
+
+const (
+	LobbyPhase    SessionPhase = "lobby"
+	QuestionPhase SessionPhase = "question" // open to the answers
+	ClosedPhase   SessionPhase = "closed"   // the answers are rejected, the results are being computed
+	RevealPhase   SessionPhase = "reveal"   // the results of the question are shown
+	EndedPhase    SessionPhase = "ended"
+	AbortedPhase  SessionPhase = "aborted"
+)
+
+// QuizSessionStatus is the state of a quiz session reported by its workflow,
+// completed with the time remaining and the participant count when it is queried.
+type QuizSessionStatus struct {
+	SessionId     SessionId    `json:"session_id"`
+	Phase         SessionPhase `json:"phase"`
+	QuestionIndex int          `json:"question_index"`
+	Paused        bool         `json:"paused"`
+	Deadline      time.Time    `json:"deadline"`
+	// TimeRemainingMs is set by the workflow when the quiz is paused, and computed from the deadline otherwise
+	TimeRemainingMs  int64 `json:"time_remaining_ms"`
+	ParticipantCount int64 `json:"participant_count"`
+}
+
+var ErrInvalidQuiz = errors.New("invalid quiz")
+
+// OptionIndex returns the index of the option with the given ID, or -1 if there is none.
+func (q *Question) OptionIndex(optionId string) int {
+	for i, option := range q.Options {
+		if option.Id == optionId {
+			return i
+		}
 	}
-	return res
+	return -1
 }
 
 func (u Username) String() string {
@@ -94,10 +432,77 @@ func (q QuizId) String() string {
 	return fmt.Sprintf("%d", q)
 }
 
-func (q QuizId) GetLeaderboardKey() string {
-	return fmt.Sprintf("quiz:%d", q)
+func (q QuizId) GetRunCounterKey() string {
+	return fmt.Sprintf("quiz_runs:%d", q)
+}
+
+// GetEditLockKey is the key of the lock serialising the edits of the quiz content.
+func (q QuizId) GetEditLockKey() string {
+	return fmt.Sprintf("quiz_edit_lock:%d", q)
+}
+
+func (q QuizId) GetActiveSessionsKey() string {
+	return fmt.Sprintf("quiz_sessions:%d", q)
+}
+
+// NewSessionId identifies a session of a quiz by its run number. It is also the ID of the session workflow.
+func NewSessionId(quizId QuizId, run int64) SessionId {
+	return SessionId(fmt.Sprintf("quiz-%d-%d", quizId, run))
+}
+
+// QuizId returns the quiz of the session, 0 if the session ID is malformed.
+func (s SessionId) QuizId() QuizId {
+	var quizId QuizId
+	var run int64
+	if _, err := fmt.Sscanf(string(s), "quiz-%d-%d", &quizId, &run); err != nil {
+		return 0
+	}
+	return quizId
+}
+
+func (s SessionId) String() string {
+	return string(s)
+}
+
+func (s SessionId) GetLeaderboardKey() string {
+	return fmt.Sprintf("session:%s", s)
+}
+
+func (s SessionId) GetStreakKey() string {
+	return fmt.Sprintf("session:%s:streaks", s)
+}
+
+// GetLastAnsweredKey is the key of the index of the last question answered by each user, which continues the streaks.
+func (s SessionId) GetLastAnsweredKey() string {
+	return fmt.Sprintf("session:%s:last_answered", s)
+}
+
+func (s SessionId) GetAnswersKey() string {
+	return fmt.Sprintf("session:%s:answers", s)
+}
+
+// GetDistributionKey is the key of the answer counts of the questions of the session.
+func (s SessionId) GetDistributionKey() string {
+	return fmt.Sprintf("session:%s:distribution", s)
+}
+
+// GetQuestionKey is the key of the answer window of the current question of the session.
+func (s SessionId) GetQuestionKey() string {
+	return fmt.Sprintf("session:%s:question", s)
+}
+
+func (s SessionId) GetParticipantsKey() string {
+	return fmt.Sprintf("session:%s:participants", s)
+}
+
+func (s SessionId) GetPinKey() string {
+	return fmt.Sprintf("session:%s:pin", s)
+}
+
+func (s SessionId) GetHostTokenKey() string {
+	return fmt.Sprintf("session:%s:host_token", s)
 }
 
-func (q QuizId) GetLockKey() string {
-	return fmt.Sprintf("quiz_in_progress:%d", q)
+func (s SessionId) GetLockKey() string {
+	return fmt.Sprintf("session_in_progress:%s", s)
 }
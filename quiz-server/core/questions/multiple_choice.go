@@ -0,0 +1,123 @@
+package questions
+
+import (
+	"fmt"
+	"math"
+
+	"quiz/core/models"
+)
+
+func init() {
+	Register(models.MultipleChoice, multipleChoice{})
+}
+
+// multipleChoice is a question with several options, any number of which can be correct.
+// The participant must select the whole correct set, unless the quiz gives partial credit.
+type multipleChoice struct{}
+
+func (multipleChoice) Validate(question *models.Question) error {
+	if err := validateOptions(question); err != nil {
+		return err
+	}
+	if len(question.CorrectAnswerIndices) == 0 {
+		return fmt.Errorf("%w: multiple-choice question has no correct answer", models.ErrInvalidQuiz)
+	}
+	correct := map[int]bool{}
+	for _, index := range question.CorrectAnswerIndices {
+		if index < 0 || index >= len(question.Options) {
+			return fmt.Errorf("%w: correct answer index %d out of range [0, %d)", models.ErrInvalidQuiz, index, len(question.Options))
+		}
+		if correct[index] {
+			return fmt.Errorf("%w: duplicate correct answer index %d", models.ErrInvalidQuiz, index)
+		}
+		correct[index] = true
+	}
+	return nil
+}
+
+func (multipleChoice) Redact(question *models.Question) models.Question {
+	return models.Question{
+		Type:               question.Type,
+		Content:            question.Content,
+		Options:            question.Options,
+		CorrectAnswerIndex: -1,
+	}
+}
+
+func (multipleChoice) Check(quiz *models.Quiz, question *models.Question, answer *models.Answer) (float64, error) {
+	selected, err := resolveOptions(question, answer.OptionIds, answer.AnswerIndices)
+	if err != nil {
+		return 0, err
+	}
+	return multipleChoiceCredit(question.CorrectAnswerIndices, selected, quiz.PartialCredit), nil
+}
+
+func (multipleChoice) Score(quiz *models.Quiz, question *models.Question, credit float64, timing *models.AnswerTiming) models.Score {
+	return speedWeightedScore(quiz, credit, timing)
+}
+
+func (multipleChoice) Reveal(question *models.Question, correct *models.CorrectAnswer) {
+	correct.CorrectAnswerIndex = -1
+	correct.CorrectAnswerIndices = question.CorrectAnswerIndices
+	for _, index := range question.CorrectAnswerIndices {
+		correct.CorrectOptionIds = append(correct.CorrectOptionIds, question.Options[index].Id)
+	}
+}
+
+func (multipleChoice) Selected(question *models.Question, answer *models.Answer) []int {
+	selected, err := resolveOptions(question, answer.OptionIds, answer.AnswerIndices)
+	if err != nil {
+		return nil
+	}
+	var indices []int
+	for i := range question.Options {
+		if selected[i] {
+			indices = append(indices, i)
+		}
+	}
+	return indices
+}
+
+// resolveOptions returns the set of selected option indices.
+func resolveOptions(question *models.Question, optionIds []string, answerIndices []int) (map[int]bool, error) {
+	selected := map[int]bool{}
+	if len(optionIds) > 0 {
+		for _, optionId := range optionIds {
+			answerIndex, err := resolveOption(question, optionId, -1)
+			if err != nil {
+				return nil, err
+			}
+			selected[answerIndex] = true
+		}
+		return selected, nil
+	}
+	for _, answerIndex := range answerIndices {
+		if _, err := resolveOption(question, "", answerIndex); err != nil {
+			return nil, err
+		}
+		selected[answerIndex] = true
+	}
+	return selected, nil
+}
+
+// multipleChoiceCredit scores a multiple-choice answer. Without partial credit only the exact correct set scores.
+// With partial credit every correct option selected earns its share of the score
+// and every incorrect option selected cancels one correct option, so selecting all options earns nothing.
+func multipleChoiceCredit(correctIndices []int, selected map[int]bool, partialCredit bool) float64 {
+	hits := 0
+	for _, index := range correctIndices {
+		if selected[index] {
+			hits++
+		}
+	}
+	misses := len(selected) - hits
+	if !partialCredit {
+		if hits == len(correctIndices) && misses == 0 {
+			return 1
+		}
+		return 0
+	}
+	credit := float64(hits-misses) / float64(len(correctIndices))
+	// round to avoid long fractions in the leaderboard, eg 1/3
+	return math.Max(0, math.Round(credit*100)/100)
+}
@@ -0,0 +1,146 @@
+package questions
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"quiz/configs"
+	"quiz/core/models"
+)
+
+// QuestionType implements the behavior of a kind of question.
+// A new kind of question is added by implementing this interface and registering it with Register.
+type QuestionType interface {
+	// Validate checks the type-specific part of the question definition.
+	Validate(question *models.Question) error
+	// Redact returns a copy of the question without the correct answer, to be sent to the participants.
+	Redact(question *models.Question) models.Question
+	// Check returns the share of the question score earned by the answer, between 0 and 1.
+	// It returns an error if the answer is malformed, eg an unknown option.
+	Check(quiz *models.Quiz, question *models.Question, answer *models.Answer) (float64, error)
+	// Score computes the score of an answer from the share returned by Check and the time it was submitted.
+	Score(quiz *models.Quiz, question *models.Question, credit float64, timing *models.AnswerTiming) models.Score
+	// Reveal sets the correct answer of the question, sent back to the participant and at the end of the question.
+	Reveal(question *models.Question, correct *models.CorrectAnswer)
+	// Selected returns the indices of the options selected by the answer, counted in the answer distribution.
+	Selected(question *models.Question, answer *models.Answer) []int
+}
+
+var registry = map[models.QuestionType]QuestionType{}
+
+// Register makes a question type available under the given name. It panics if the name is already registered.
+func Register(name models.QuestionType, questionType QuestionType) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Sprintf("question type already registered: %s", name))
+	}
+	registry[name] = questionType
+}
+
+// Get returns the implementation of the question type, an empty name being a single-choice question.
+func Get(name models.QuestionType) (QuestionType, error) {
+	if name == "" {
+		name = models.SingleChoice
+	}
+	questionType, ok := registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown question type: %s", name)
+	}
+	return questionType, nil
+}
+
+// ValidateQuiz checks that the quiz has at least 1 question and every question is valid.
+func ValidateQuiz(quiz *models.Quiz) error {
+	if len(quiz.Questions) == 0 {
+		return fmt.Errorf("%w: quiz has no questions", models.ErrInvalidQuiz)
+	}
+	if quiz.Scoring != nil && (quiz.Scoring.MinScore < 0 || quiz.Scoring.MinScore > quiz.Scoring.MaxScore) {
+		return fmt.Errorf("%w: scoring must satisfy 0 <= min score <= max score", models.ErrInvalidQuiz)
+	}
+	if quiz.LobbySeconds < 0 || quiz.QuestionSeconds < 0 || quiz.RevealSeconds < 0 {
+		return fmt.Errorf("%w: durations must not be negative", models.ErrInvalidQuiz)
+	}
+	if quiz.StartMode != "" && quiz.StartMode != models.TimerStart && quiz.StartMode != models.HostStart {
+		return fmt.Errorf("%w: unknown start mode: %s", models.ErrInvalidQuiz, quiz.StartMode)
+	}
+	if quiz.AutoStartPlayers < 0 {
+		return fmt.Errorf("%w: auto start players must not be negative", models.ErrInvalidQuiz)
+	}
+	if quiz.Scoring != nil && (quiz.Scoring.StreakBonus < 0 || quiz.Scoring.MaxStreakBonus < 0) {
+		return fmt.Errorf("%w: streak bonus must not be negative", models.ErrInvalidQuiz)
+	}
+	for i := range quiz.Questions {
+		if err := ValidateQuestion(&quiz.Questions[i]); err != nil {
+			return fmt.Errorf("question %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateQuestion checks that the question has content and is valid for its type.
+func ValidateQuestion(question *models.Question) error {
+	if strings.TrimSpace(question.Content) == "" {
+		return fmt.Errorf("%w: content is empty", models.ErrInvalidQuiz)
+	}
+	if question.TimeLimitSeconds < 0 {
+		return fmt.Errorf("%w: time limit must not be negative", models.ErrInvalidQuiz)
+	}
+	questionType, err := Get(question.Type)
+	if err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInvalidQuiz, err)
+	}
+	return questionType.Validate(question)
+}
+
+// RedactQuiz returns a copy of the quiz without the correct answers, to be sent to the participants.
+func RedactQuiz(quiz *models.Quiz) *models.Quiz {
+	res := *quiz
+	res.Questions = make([]models.Question, 0, len(quiz.Questions))
+	for i := range quiz.Questions {
+		res.Questions = append(res.Questions, RedactQuestion(&quiz.Questions[i]))
+	}
+	return &res
+}
+
+// RedactQuestion returns a copy of the question without its correct answers.
+func RedactQuestion(question *models.Question) models.Question {
+	questionType, err := Get(question.Type)
+	if err != nil {
+		// a quiz is validated when it is saved, so this should never happen
+		return models.Question{Type: question.Type, Content: question.Content}
+	}
+	return questionType.Redact(question)
+}
+
+// speedWeightedScore is the default scoring: the score of a correct answer decreases linearly
+// over the answer window, and is multiplied by the share earned by the answer.
+func speedWeightedScore(quiz *models.Quiz, credit float64, timing *models.AnswerTiming) models.Score {
+	if credit <= 0 {
+		return 0
+	}
+	maxScore, minScore := models.Score(configs.MaxQuestionScore), models.Score(configs.MinQuestionScore)
+	if quiz.Scoring != nil {
+		maxScore, minScore = quiz.Scoring.MaxScore, quiz.Scoring.MinScore
+	}
+	elapsed := 0.0
+	if window := timing.Deadline.Sub(timing.StartedAt); window > 0 {
+		elapsed = float64(timing.AnsweredAt.Sub(timing.StartedAt)) / float64(window)
+		elapsed = math.Min(1, math.Max(0, elapsed))
+	}
+	score := float64(maxScore) - float64(maxScore-minScore)*elapsed
+	return models.Score(math.Round(score * credit))
+}
+
+// StreakScore applies the streak bonus to the score of an answer, streak being the number of
+// consecutive correct answers including this one.
+func StreakScore(quiz *models.Quiz, score models.Score, streak int) models.Score {
+	if streak <= 1 {
+		return score
+	}
+	bonus, maxBonus := configs.StreakBonus, configs.MaxStreakBonus
+	if quiz.Scoring != nil {
+		bonus, maxBonus = quiz.Scoring.StreakBonus, quiz.Scoring.MaxStreakBonus
+	}
+	multiplier := 1 + math.Min(bonus*float64(streak-1), maxBonus)
+	return models.Score(math.Round(float64(score) * multiplier))
+}
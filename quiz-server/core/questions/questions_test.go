@@ -0,0 +1,147 @@
+package questions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"quiz/core/data"
+	"quiz/core/models"
+)
+
+func TestValidateQuiz(t *testing.T) {
+	for _, quiz := range data.QuizData {
+		if err := ValidateQuiz(quiz); err != nil {
+			t.Errorf("quiz %d: %v", quiz.Id, err)
+		}
+	}
+
+	options := []models.Option{{Id: "a", Text: "A"}, {Id: "b", Text: "B"}}
+	invalid := map[string]models.Question{
+		"empty content":           {Options: options},
+		"1 option":                {Content: "q", Options: options[:1]},
+		"duplicate option id":     {Content: "q", Options: []models.Option{{Id: "a", Text: "A"}, {Id: "a", Text: "B"}}},
+		"correct index too large": {Content: "q", Options: options, CorrectAnswerIndex: 2},
+		"no correct set":          {Type: models.MultipleChoice, Content: "q", Options: options},
+		"no accepted answer":      {Type: models.Text, Content: "q"},
+		"unknown type":            {Type: "essay", Content: "q"},
+	}
+	for name, question := range invalid {
+		quiz := &models.Quiz{Questions: []models.Question{question}}
+		if err := ValidateQuiz(quiz); !errors.Is(err, models.ErrInvalidQuiz) {
+			t.Errorf("%s: expected ErrInvalidQuiz, got %v", name, err)
+		}
+	}
+}
+
+func TestRedactQuiz(t *testing.T) {
+	for _, quiz := range data.QuizData {
+		for _, question := range RedactQuiz(quiz).Questions {
+			if question.CorrectAnswerIndex != -1 || question.CorrectAnswerIndices != nil || question.AcceptedAnswers != nil {
+				t.Errorf("quiz %d: answer not redacted: %+v", quiz.Id, question)
+			}
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	options := []models.Option{{Id: "a", Text: "A"}, {Id: "b", Text: "B"}, {Id: "c", Text: "C"}, {Id: "d", Text: "D"}}
+	singleQuestion := &models.Question{Content: "q", Options: options, CorrectAnswerIndex: 1}
+	multipleQuestion := &models.Question{Type: models.MultipleChoice, Content: "q", Options: options, CorrectAnswerIndices: []int{0, 2}}
+	textQuestion := &models.Question{Type: models.Text, Content: "q", AcceptedAnswers: []string{"Café au lait"}}
+	typoQuestion := &models.Question{Type: models.Text, Content: "q", AcceptedAnswers: []string{"puppy"}, Matching: &models.TextMatching{MaxTypos: 1}}
+	strictQuestion := &models.Question{Type: models.Text, Content: "q", AcceptedAnswers: []string{"Café"}, Matching: &models.TextMatching{CaseSensitive: true, KeepDiacritics: true}}
+
+	tests := []struct {
+		name          string
+		question      *models.Question
+		partialCredit bool
+		answer        models.Answer
+		credit        float64
+	}{
+		{"single by index", singleQuestion, false, models.Answer{AnswerIndex: 1}, 1},
+		{"single by option id", singleQuestion, false, models.Answer{AnswerIndex: 1, OptionId: "c"}, 0},
+		{"multiple exact", multipleQuestion, false, models.Answer{OptionIds: []string{"c", "a"}}, 1},
+		{"multiple partial without credit", multipleQuestion, false, models.Answer{AnswerIndices: []int{0}}, 0},
+		{"multiple partial with credit", multipleQuestion, true, models.Answer{AnswerIndices: []int{0}}, 0.5},
+		{"multiple wrong cancels right", multipleQuestion, true, models.Answer{AnswerIndices: []int{0, 1}}, 0},
+		{"multiple all options", multipleQuestion, true, models.Answer{AnswerIndices: []int{0, 1, 2, 3}}, 0},
+		{"text normalized", textQuestion, false, models.Answer{TextAnswer: "  cafe AU   lait "}, 1},
+		{"text typo not allowed", textQuestion, false, models.Answer{TextAnswer: "cafe au lai"}, 0},
+		{"text typo allowed", typoQuestion, false, models.Answer{TextAnswer: "pupy"}, 1},
+		{"text too many typos", typoQuestion, false, models.Answer{TextAnswer: "pup"}, 0},
+		{"text empty", typoQuestion, false, models.Answer{}, 0},
+		{"text strict", strictQuestion, false, models.Answer{TextAnswer: "cafe"}, 0},
+	}
+	for _, test := range tests {
+		questionType, err := Get(test.question.Type)
+		if err != nil {
+			t.Fatal(err)
+		}
+		quiz := &models.Quiz{PartialCredit: test.partialCredit}
+		credit, err := questionType.Check(quiz, test.question, &test.answer)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if credit != test.credit {
+			t.Errorf("%s: expected credit %v, got %v", test.name, test.credit, credit)
+		}
+	}
+
+	singleChoice, _ := Get(models.SingleChoice)
+	for _, answer := range []models.Answer{{AnswerIndex: 4}, {AnswerIndex: -1}, {OptionId: "e"}} {
+		if _, err := singleChoice.Check(&models.Quiz{}, singleQuestion, &answer); err == nil {
+			t.Errorf("expected error for invalid answer %+v", answer)
+		}
+	}
+}
+
+func TestSpeedWeightedScore(t *testing.T) {
+	startedAt := time.Now()
+	deadline := startedAt.Add(10 * time.Second)
+	tests := []struct {
+		name    string
+		scoring *models.Scoring
+		credit  float64
+		elapsed time.Duration
+		score   models.Score
+	}{
+		{"immediate", nil, 1, 0, 1000},
+		{"half window", nil, 1, 5 * time.Second, 750},
+		{"at deadline", nil, 1, 10 * time.Second, 500},
+		{"after deadline", nil, 1, 11 * time.Second, 500},
+		{"partial credit", nil, 0.5, 0, 500},
+		{"wrong", nil, 0, 0, 0},
+		{"flat scoring", &models.Scoring{MaxScore: 1, MinScore: 1}, 1, 5 * time.Second, 1},
+	}
+	for _, test := range tests {
+		timing := &models.AnswerTiming{StartedAt: startedAt, Deadline: deadline, AnsweredAt: startedAt.Add(test.elapsed)}
+		score := speedWeightedScore(&models.Quiz{Scoring: test.scoring}, test.credit, timing)
+		if score != test.score {
+			t.Errorf("%s: expected %v, got %v", test.name, test.score, score)
+		}
+	}
+}
+
+func TestStreakScore(t *testing.T) {
+	tests := []struct {
+		name    string
+		scoring *models.Scoring
+		streak  int
+		score   models.Score
+	}{
+		{"wrong", nil, 0, 1000},
+		{"first correct", nil, 1, 1000},
+		{"second correct", nil, 2, 1100},
+		{"capped", nil, 10, 1500},
+		{"disabled by quiz scoring", &models.Scoring{MaxScore: 1000, MinScore: 500}, 3, 1000},
+		{"quiz bonus", &models.Scoring{MaxScore: 1000, MinScore: 500, StreakBonus: 0.25, MaxStreakBonus: 1}, 3, 1500},
+	}
+	for _, test := range tests {
+		score := StreakScore(&models.Quiz{Scoring: test.scoring}, 1000, test.streak)
+		if score != test.score {
+			t.Errorf("%s: expected %v, got %v", test.name, test.score, score)
+		}
+	}
+}
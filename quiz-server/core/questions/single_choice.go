@@ -0,0 +1,98 @@
+package questions
+
+import (
+	"fmt"
+	"strings"
+
+	"quiz/core/models"
+)
+
+func init() {
+	Register(models.SingleChoice, singleChoice{})
+}
+
+// singleChoice is a question with several options and exactly 1 correct option.
+type singleChoice struct{}
+
+func (singleChoice) Validate(question *models.Question) error {
+	if err := validateOptions(question); err != nil {
+		return err
+	}
+	if question.CorrectAnswerIndex < 0 || question.CorrectAnswerIndex >= len(question.Options) {
+		return fmt.Errorf("%w: correct answer index %d out of range [0, %d)",
+			models.ErrInvalidQuiz, question.CorrectAnswerIndex, len(question.Options))
+	}
+	return nil
+}
+
+func (singleChoice) Redact(question *models.Question) models.Question {
+	return models.Question{
+		Type:               question.Type,
+		Content:            question.Content,
+		Options:            question.Options,
+		CorrectAnswerIndex: -1,
+	}
+}
+
+func (singleChoice) Check(quiz *models.Quiz, question *models.Question, answer *models.Answer) (float64, error) {
+	answerIndex, err := resolveOption(question, answer.OptionId, answer.AnswerIndex)
+	if err != nil {
+		return 0, err
+	}
+	if answerIndex == question.CorrectAnswerIndex {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (singleChoice) Score(quiz *models.Quiz, question *models.Question, credit float64, timing *models.AnswerTiming) models.Score {
+	return speedWeightedScore(quiz, credit, timing)
+}
+
+func (singleChoice) Reveal(question *models.Question, correct *models.CorrectAnswer) {
+	correct.CorrectAnswerIndex = question.CorrectAnswerIndex
+	correct.CorrectOptionId = question.Options[question.CorrectAnswerIndex].Id
+}
+
+func (singleChoice) Selected(question *models.Question, answer *models.Answer) []int {
+	answerIndex, err := resolveOption(question, answer.OptionId, answer.AnswerIndex)
+	if err != nil {
+		return nil
+	}
+	return []int{answerIndex}
+}
+
+// validateOptions checks that the question has at least 2 non-empty options with unique IDs.
+func validateOptions(question *models.Question) error {
+	if len(question.Options) < 2 {
+		return fmt.Errorf("%w: question must have at least 2 options, got %d", models.ErrInvalidQuiz, len(question.Options))
+	}
+	optionIds := map[string]bool{}
+	for i, option := range question.Options {
+		if option.Id == "" {
+			return fmt.Errorf("%w: option %d has no id", models.ErrInvalidQuiz, i)
+		}
+		if optionIds[option.Id] {
+			return fmt.Errorf("%w: duplicate option id %s", models.ErrInvalidQuiz, option.Id)
+		}
+		optionIds[option.Id] = true
+		if strings.TrimSpace(option.Text) == "" && option.MediaUrl == "" {
+			return fmt.Errorf("%w: option %d is empty", models.ErrInvalidQuiz, i)
+		}
+	}
+	return nil
+}
+
+// resolveOption returns the index of the selected option, identified by its ID if set or by its index otherwise.
+func resolveOption(question *models.Question, optionId string, answerIndex int) (int, error) {
+	if optionId != "" {
+		answerIndex = question.OptionIndex(optionId)
+		if answerIndex < 0 {
+			return 0, fmt.Errorf("unknown option: %s", optionId)
+		}
+	}
+	if answerIndex < 0 || answerIndex >= len(question.Options) {
+		return 0, fmt.Errorf("invalid answer index: %d", answerIndex)
+	}
+	return answerIndex, nil
+}
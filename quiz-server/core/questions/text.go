@@ -0,0 +1,121 @@
+package questions
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"quiz/core/models"
+)
+
+func init() {
+	Register(models.Text, text{})
+}
+
+// text is a question answered by typing the answer, eg the spelling of a word.
+type text struct{}
+
+func (text) Validate(question *models.Question) error {
+	if len(question.AcceptedAnswers) == 0 {
+		return fmt.Errorf("%w: text question has no accepted answer", models.ErrInvalidQuiz)
+	}
+	for i, answer := range question.AcceptedAnswers {
+		if strings.TrimSpace(answer) == "" {
+			return fmt.Errorf("%w: accepted answer %d is empty", models.ErrInvalidQuiz, i)
+		}
+	}
+	if question.Matching != nil && question.Matching.MaxTypos < 0 {
+		return fmt.Errorf("%w: max typos must not be negative", models.ErrInvalidQuiz)
+	}
+	return nil
+}
+
+func (text) Redact(question *models.Question) models.Question {
+	return models.Question{
+		Type:               question.Type,
+		Content:            question.Content,
+		CorrectAnswerIndex: -1,
+		Matching:           question.Matching,
+	}
+}
+
+func (text) Check(quiz *models.Quiz, question *models.Question, answer *models.Answer) (float64, error) {
+	if checkTextAnswer(question, answer.TextAnswer) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (text) Score(quiz *models.Quiz, question *models.Question, credit float64, timing *models.AnswerTiming) models.Score {
+	return speedWeightedScore(quiz, credit, timing)
+}
+
+func (text) Reveal(question *models.Question, correct *models.CorrectAnswer) {
+	correct.CorrectAnswerIndex = -1
+	correct.CanonicalAnswer = question.AcceptedAnswers[0]
+}
+
+// Selected returns no option, a text question has none.
+func (text) Selected(question *models.Question, answer *models.Answer) []int {
+	return nil
+}
+
+// checkTextAnswer reports whether the typed answer matches one of the accepted answers of the question.
+func checkTextAnswer(question *models.Question, answer string) bool {
+	matching := question.Matching
+	if matching == nil {
+		matching = &models.TextMatching{}
+	}
+	normalizedAnswer := normalizeText(answer, matching)
+	if normalizedAnswer == "" {
+		return false
+	}
+	for _, accepted := range question.AcceptedAnswers {
+		if levenshtein(normalizedAnswer, normalizeText(accepted, matching)) <= matching.MaxTypos {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeText(s string, matching *models.TextMatching) string {
+	if !matching.KeepWhitespace {
+		s = strings.Join(strings.Fields(s), " ")
+	}
+	if !matching.CaseSensitive {
+		s = strings.ToLower(s)
+	}
+	if !matching.KeepDiacritics {
+		// decompose the characters then drop the combining marks, eg "é" -> "e" + "́" -> "e"
+		t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+		if res, _, err := transform.String(t, s); err == nil {
+			s = res
+		}
+	}
+	return s
+}
+
+// levenshtein returns the edit distance between a and b, counted in runes.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
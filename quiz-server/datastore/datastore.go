@@ -2,8 +2,13 @@ package datastore
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/samber/lo"
@@ -19,32 +24,275 @@ var ErrQuizInProgress = errors.New("quiz already in progress")
 
 var ErrUserInQuiz = errors.New("user already in quiz")
 
-func MarkQuizAsInProgress(ctx context.Context, quizId models.QuizId) error {
-	ok, err := client.SetNX(ctx, quizId.GetLockKey(), "locked", configs.QuizMaxDuration).Result()
+var ErrPinNotFound = errors.New("invalid PIN")
+
+var ErrUserNotInQuiz = errors.New("user not in quiz")
+
+var ErrQuestionAnswered = errors.New("question already answered")
+
+var ErrAnswerTooLate = errors.New("answer after the question deadline")
+
+var ErrQuizPaused = errors.New("quiz is paused")
+
+var ErrQuestionNotInProgress = errors.New("question is not in progress")
+
+// MarkQuizAsInProgress locks the quiz session, and registers it as an active session of the quiz until it expires.
+func MarkQuizAsInProgress(ctx context.Context, sessionId models.SessionId, quizId models.QuizId, expiration time.Duration) error {
+	ok, err := client.SetNX(ctx, sessionId.GetLockKey(), quizId.String(), expiration).Result()
 	if err != nil {
 		return err
 	}
 	if !ok {
 		return ErrQuizInProgress
 	}
-	return nil
+	return client.ZAdd(ctx, quizId.GetActiveSessionsKey(), redis.Z{
+		Score:  float64(time.Now().Add(expiration).UnixMilli()),
+		Member: sessionId.String(),
+	}).Err()
 }
 
-func MarkQuizAsFinished(ctx context.Context, quizId models.QuizId) error {
-	res, err := client.Del(ctx, quizId.GetLockKey()).Result()
+// extendExpirationScript adds ARGV[1] milliseconds, which may be negative, to the expiration of the keys which exist.
+// The keys are kept at least 1 millisecond, the expiration never deletes them.
+var extendExpirationScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	local ttl = redis.call("PTTL", key)
+	if ttl > 0 then
+		redis.call("PEXPIRE", key, math.max(ttl + tonumber(ARGV[1]), 1))
+	end
+end
+return 0
+`)
+
+// ExtendQuizLock delays the expiration of the runtime state of the session when the host pauses the quiz or adds time:
+// the session lock, host token and PIN, and the participants, their scores and answers.
+// A negative extension brings the expiration forward.
+func ExtendQuizLock(ctx context.Context, sessionId models.SessionId, extension time.Duration) error {
+	keys, err := sessionKeys(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	keys = append(keys, sessionId.GetParticipantsKey(), sessionId.GetLeaderboardKey(), sessionId.GetStreakKey(),
+		sessionId.GetLastAnsweredKey(), sessionId.GetAnswersKey(), sessionId.GetDistributionKey())
+	// the leaderboard has all the users who joined, including the disconnected ones who can still resume the session
+	usernames, err := client.ZRange(ctx, sessionId.GetLeaderboardKey(), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, username := range usernames {
+		keys = append(keys, userInQuizKey(sessionId, models.Username(username)))
+	}
+	if err = extendExpirationScript.Run(ctx, client, keys, extension.Milliseconds()).Err(); err != nil {
+		return err
+	}
+	quizId, err := getSessionQuizId(ctx, sessionId)
+	if err != nil || quizId == 0 {
+		return err
+	}
+	ttl, err := client.PTTL(ctx, sessionId.GetLockKey()).Result()
+	if err != nil {
+		return err
+	}
+	return client.ZAddXX(ctx, quizId.GetActiveSessionsKey(), redis.Z{
+		Score:  float64(time.Now().Add(ttl).UnixMilli()),
+		Member: sessionId.String(),
+	}).Err()
+}
+
+// getSessionQuizId returns the quiz of the session stored in the session lock, 0 if the session is not in progress.
+func getSessionQuizId(ctx context.Context, sessionId models.SessionId) (models.QuizId, error) {
+	quizId, err := client.Get(ctx, sessionId.GetLockKey()).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return models.QuizId(quizId), err
+}
+
+// sessionKeys returns the keys which live as long as the quiz session.
+func sessionKeys(ctx context.Context, sessionId models.SessionId) ([]string, error) {
+	keys := []string{sessionId.GetLockKey(), sessionId.GetHostTokenKey(), sessionId.GetPinKey(), sessionId.GetQuestionKey()}
+	pin, err := client.Get(ctx, sessionId.GetPinKey()).Result()
+	if errors.Is(err, redis.Nil) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append(keys, pinKey(pin)), nil
+}
+
+func pinKey(pin string) string {
+	return fmt.Sprintf("pin:%s", pin)
+}
+
+// saveQuestionScript replaces the answer window of the session, which expires with the session lock KEYS[2].
+var saveQuestionScript = redis.NewScript(`
+redis.call("DEL", KEYS[1])
+redis.call("HSET", KEYS[1], "index", ARGV[1], "started_at", ARGV[2], "deadline", ARGV[3], "paused", ARGV[4], "closed", ARGV[5])
+local ttl = redis.call("PTTL", KEYS[2])
+if ttl > 0 then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 0
+`)
+
+// SaveQuestionWindow stores the answer window of the current question, against which all the instances check the answers.
+func SaveQuestionWindow(ctx context.Context, sessionId models.SessionId, window *models.QuestionWindow) error {
+	keys := []string{sessionId.GetQuestionKey(), sessionId.GetLockKey()}
+	return saveQuestionScript.Run(ctx, client, keys, window.QuestionIndex, window.StartedAt.UnixMilli(),
+		window.Deadline.UnixMilli(), window.Paused, window.Closed).Err()
+}
+
+// updateQuestionScript sets fields of the answer window of the session, if there is a question in progress.
+var updateQuestionScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 0 then
+	return 0
+end
+return redis.call("HSET", KEYS[1], unpack(ARGV))
+`)
+
+func updateQuestionWindow(ctx context.Context, sessionId models.SessionId, values ...any) error {
+	return updateQuestionScript.Run(ctx, client, []string{sessionId.GetQuestionKey()}, values...).Err()
+}
+
+// PauseQuestionWindow rejects the answers to the current question until ResumeQuestionWindow reopens it,
+// keeping the answer time left.
+func PauseQuestionWindow(ctx context.Context, sessionId models.SessionId, remaining time.Duration) error {
+	return updateQuestionWindow(ctx, sessionId, "paused", true, "remaining", remaining.Milliseconds())
+}
+
+// ResumeQuestionWindow accepts the answers to the current question again, within its window shifted by the pause.
+// A closed question stays closed.
+func ResumeQuestionWindow(ctx context.Context, sessionId models.SessionId, startedAt, deadline time.Time) error {
+	return updateQuestionWindow(ctx, sessionId,
+		"paused", false, "started_at", startedAt.UnixMilli(), "deadline", deadline.UnixMilli())
+}
+
+// ExtendQuestionWindow moves the deadline of the current question, with the answer time left if paused.
+func ExtendQuestionWindow(ctx context.Context, sessionId models.SessionId, deadline time.Time, remaining time.Duration) error {
+	return updateQuestionWindow(ctx, sessionId, "deadline", deadline.UnixMilli(), "remaining", remaining.Milliseconds())
+}
+
+// CloseQuestionWindow rejects the answers to the current question, whatever its deadline.
+// A question skipped while paused is no longer paused.
+func CloseQuestionWindow(ctx context.Context, sessionId models.SessionId) error {
+	return updateQuestionWindow(ctx, sessionId, "closed", true, "paused", false)
+}
+
+// GetQuestionWindow returns the answer window of the current question, nil if no question has started.
+func GetQuestionWindow(ctx context.Context, sessionId models.SessionId) (*models.QuestionWindow, error) {
+	values, err := client.HMGet(ctx, sessionId.GetQuestionKey(),
+		"index", "started_at", "deadline", "paused", "closed", "remaining").Result()
+	if err != nil {
+		return nil, err
+	}
+	if values[0] == nil {
+		return nil, nil
+	}
+	number := func(value any) int64 {
+		n, _ := strconv.ParseInt(fmt.Sprint(value), 10, 64)
+		return n
+	}
+	return &models.QuestionWindow{
+		QuestionIndex: int(number(values[0])),
+		StartedAt:     time.UnixMilli(number(values[1])),
+		Deadline:      time.UnixMilli(number(values[2])),
+		Paused:        values[3] == "1",
+		Closed:        values[4] == "1",
+		Remaining:     time.Duration(number(values[5])) * time.Millisecond,
+	}, nil
+}
+
+// LockQuizEdit takes the edit lock of the quiz with the given token. It returns false if the lock is already taken.
+// The lock expires in case its owner never releases it.
+func LockQuizEdit(ctx context.Context, quizId models.QuizId, token string, expiration time.Duration) (bool, error) {
+	return client.SetNX(ctx, quizId.GetEditLockKey(), token, expiration).Result()
+}
+
+// unlockScript deletes the lock KEYS[1] if it is still owned by the token ARGV[1].
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// UnlockQuizEdit releases the edit lock of the quiz, unless it expired and was taken by another edit meanwhile.
+func UnlockQuizEdit(ctx context.Context, quizId models.QuizId, token string) error {
+	return unlockScript.Run(ctx, client, []string{quizId.GetEditLockKey()}, token).Err()
+}
+
+// SaveSessionPin maps the PIN to the quiz session. It returns false if the PIN is already used by another session.
+func SaveSessionPin(ctx context.Context, pin string, session *models.SessionPin, expiration time.Duration) (bool, error) {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return false, err
+	}
+	ok, err := client.SetNX(ctx, pinKey(pin), value, expiration).Result()
+	if err != nil || !ok {
+		return false, err
+	}
+	return true, client.Set(ctx, session.SessionId.GetPinKey(), pin, expiration).Err()
+}
+
+// GetSessionPin returns the quiz session of the PIN.
+func GetSessionPin(ctx context.Context, pin string) (*models.SessionPin, error) {
+	value, err := client.Get(ctx, pinKey(pin)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrPinNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	session := &models.SessionPin{}
+	if err = json.Unmarshal(value, session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// NextQuizRun increments the run counter of the quiz, which identifies its new session.
+func NextQuizRun(ctx context.Context, quizId models.QuizId) (int64, error) {
+	return client.Incr(ctx, quizId.GetRunCounterKey()).Result()
+}
+
+// GetLastQuizRun returns the run number of the last session of the quiz, 0 if the quiz has never been started.
+func GetLastQuizRun(ctx context.Context, quizId models.QuizId) (int64, error) {
+	run, err := client.Get(ctx, quizId.GetRunCounterKey()).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return run, err
+}
+
+func MarkQuizAsFinished(ctx context.Context, sessionId models.SessionId) error {
+	quizId, err := getSessionQuizId(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	if quizId != 0 {
+		if err = client.ZRem(ctx, quizId.GetActiveSessionsKey(), sessionId.String()).Err(); err != nil {
+			return err
+		}
+	}
+	keys, err := sessionKeys(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	res, err := client.Del(ctx, keys...).Result()
 	if err != nil {
 		return err
 	}
 	// If the delete result is 0, the key didn't exist (lock might have expired or not set)
 	if res == 0 {
-		fmt.Println("lock does not exist or already released", quizId)
+		fmt.Println("lock does not exist or already released", sessionId)
 		return nil
 	}
 	return nil
 }
 
-func CheckQuizInProgress(ctx context.Context, quizId models.QuizId) error {
-	exists, err := client.Exists(ctx, quizId.GetLockKey()).Result()
+// CheckSessionInProgress returns ErrQuizInProgress if the session is in progress.
+func CheckSessionInProgress(ctx context.Context, sessionId models.SessionId) error {
+	exists, err := client.Exists(ctx, sessionId.GetLockKey()).Result()
 	if err != nil {
 		return fmt.Errorf("get lock error: %w", err)
 	}
@@ -54,39 +302,399 @@ func CheckQuizInProgress(ctx context.Context, quizId models.QuizId) error {
 	return nil
 }
 
-func MarkUserAsInQuiz(ctx context.Context, quizId models.QuizId, username models.Username) error {
-	key := fmt.Sprintf("user_in_quiz:%d:%s", quizId, username)
-	ok, err := client.SetNX(ctx, key, "locked", configs.QuizMaxDuration).Result()
+// CheckQuizInProgress returns ErrQuizInProgress if a session of the quiz is in progress.
+func CheckQuizInProgress(ctx context.Context, quizId models.QuizId) error {
+	key := quizId.GetActiveSessionsKey()
+	// drop the sessions which expired without finishing
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := client.ZRemRangeByScore(ctx, key, "-inf", now).Err(); err != nil {
+		return fmt.Errorf("get active sessions error: %w", err)
+	}
+	count, err := client.ZCard(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("get active sessions error: %w", err)
+	}
+	if count > 0 {
+		return ErrQuizInProgress
+	}
+	return nil
+}
+
+// SaveHostToken stores the token authenticating the host of the quiz session.
+func SaveHostToken(ctx context.Context, sessionId models.SessionId, token string, expiration time.Duration) error {
+	return client.Set(ctx, sessionId.GetHostTokenKey(), token, expiration).Err()
+}
+
+// GetHostToken returns the token of the host of the quiz session, or an empty string if the quiz is not in progress.
+func GetHostToken(ctx context.Context, sessionId models.SessionId) (string, error) {
+	token, err := client.Get(ctx, sessionId.GetHostTokenKey()).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	return token, err
+}
+
+func userInQuizKey(sessionId models.SessionId, username models.Username) string {
+	return fmt.Sprintf("user_in_session:%s:%s", sessionId, username)
+}
+
+func MarkUserAsInQuiz(
+	ctx context.Context, sessionId models.SessionId, username models.Username, socketId string, expiration time.Duration,
+) error {
+	ok, err := client.SetNX(ctx, userInQuizKey(sessionId, username), "locked", expiration).Result()
 	if err != nil {
 		return err
 	}
 	if !ok {
 		return ErrUserInQuiz
 	}
+	return AddParticipant(ctx, sessionId, username, socketId, expiration)
+}
+
+// CheckUserInQuiz returns ErrUserNotInQuiz if the user hasn't joined the quiz session, or the session ended.
+func CheckUserInQuiz(ctx context.Context, sessionId models.SessionId, username models.Username) error {
+	exists, err := client.Exists(ctx, userInQuizKey(sessionId, username)).Result()
+	if err != nil {
+		return err
+	}
+	if exists == 0 {
+		return ErrUserNotInQuiz
+	}
 	return nil
 }
 
-func MarkUserAsNotInQuiz(ctx context.Context, quizId models.QuizId, username models.Username) error {
-	res, err := client.Del(ctx, fmt.Sprintf("user_in_quiz:%d:%s", quizId, username)).Result()
+// AddParticipant adds the user to the participants connected to the quiz session, which is a hash of the socket
+// of each user, replacing the socket the user was connected with if any.
+// The user is also added to the leaderboard with a score of 0 if not already in it, so that every player is ranked.
+func AddParticipant(
+	ctx context.Context, sessionId models.SessionId, username models.Username, socketId string, expiration time.Duration,
+) error {
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, sessionId.GetParticipantsKey(), username.String(), socketId)
+		pipe.Expire(ctx, sessionId.GetParticipantsKey(), expiration)
+		pipe.ZAddNX(ctx, sessionId.GetLeaderboardKey(), redis.Z{Member: username.String()})
+		pipe.Expire(ctx, sessionId.GetLeaderboardKey(), expiration)
+		return nil
+	})
+	return err
+}
+
+// removeParticipantScript removes the user ARGV[1] from the participants KEYS[1] if connected with the socket ARGV[2].
+var removeParticipantScript = redis.NewScript(`
+if redis.call("HGET", KEYS[1], ARGV[1]) == ARGV[2] then
+	return redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// RemoveParticipant removes the user disconnected from the socket from the participants of the quiz session,
+// unless the user has resumed the session with another socket in the meantime, possibly on another instance.
+// The user stays in the quiz until the session ends. It returns whether the user was removed.
+func RemoveParticipant(ctx context.Context, sessionId models.SessionId, username models.Username, socketId string) (bool, error) {
+	removed, err := removeParticipantScript.Run(ctx, client, []string{sessionId.GetParticipantsKey()},
+		username.String(), socketId).Int()
+	return removed == 1, err
+}
+
+// ClearParticipants marks all the users of the quiz session as not in the quiz,
+// including the disconnected ones, which are only in the leaderboard.
+func ClearParticipants(ctx context.Context, sessionId models.SessionId) error {
+	connected, err := client.HKeys(ctx, sessionId.GetParticipantsKey()).Result()
+	if err != nil {
+		return err
+	}
+	ranked, err := client.ZRange(ctx, sessionId.GetLeaderboardKey(), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	keys := lo.Map(lo.Union(connected, ranked), func(username string, index int) string {
+		return userInQuizKey(sessionId, models.Username(username))
+	})
+	return client.Del(ctx, append(keys, sessionId.GetParticipantsKey())...).Err()
+}
+
+// GetParticipants returns the users in the quiz session, sorted by username.
+func GetParticipants(ctx context.Context, sessionId models.SessionId) ([]models.Username, error) {
+	usernames, err := client.HKeys(ctx, sessionId.GetParticipantsKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	slices.Sort(usernames)
+	return lo.Map(usernames, func(username string, index int) models.Username {
+		return models.Username(username)
+	}), nil
+}
+
+// CountParticipants returns the number of users in the quiz session.
+func CountParticipants(ctx context.Context, sessionId models.SessionId) (int64, error) {
+	return client.HLen(ctx, sessionId.GetParticipantsKey()).Result()
+}
+
+func MarkUserAsNotInQuiz(ctx context.Context, sessionId models.SessionId, username models.Username) error {
+	if err := client.HDel(ctx, sessionId.GetParticipantsKey(), username.String()).Err(); err != nil {
+		return err
+	}
+	res, err := client.Del(ctx, userInQuizKey(sessionId, username)).Result()
 	if err != nil {
 		return err
 	}
 	// If the delete result is 0, the key didn't exist (lock might have expired or not set)
 	if res == 0 {
-		fmt.Println("lock does not exist or already released", quizId)
+		fmt.Println("lock does not exist or already released", sessionId)
 		return nil
 	}
 	return nil
 }
 
-func AddOrUpdateUserScore(ctx context.Context, quizId models.QuizId, username models.Username, dScore int) (int, error) {
-	newScore, err := client.ZIncrBy(ctx, quizId.GetLeaderboardKey(), float64(dScore), username.String()).Result()
-	return int(newScore), err
+// submitAnswerScript records the answer of a user to a question, once, and adds its points to the user score.
+// The answer is first checked against the answer window KEYS[5] of the session, using the clock of Redis so that
+// all the instances agree: it returns -1 if the question is over, ie closed, followed by another question,
+// or past its deadline plus the grace period of ARGV[9] milliseconds, -2 if the quiz is paused,
+// and -3 if the question hasn't started.
+// It returns false if the user has already answered the question, and otherwise the streak, the new score
+// and the rank of the user, and the top ARGV[6] users with their scores, as of this answer.
+// The answers of the session are stored in a hash, whose "{question index}:{username}" fields hold
+// "{answer time};{response time};{correct};{points};{options}", see GetAnswers.
+// The streak is set to 0 after a wrong answer, or incremented after a correct answer to the question following
+// the last one answered, restarting at 1 if a question was answered wrong or not at all.
+// The index of the last question answered by each user is stored in another hash, KEYS[6], as usernames are free text
+// and can't share the streak hash with a suffixed field.
+// The answer is also counted in the distribution hash of the session: "{question index}:total", "{question index}:correct"
+// and "{question index}:option:{option index}" for each selected option of the comma-separated ARGV[8],
+// and the fastest correct answer is kept in "{question index}:fastest_user" and "{question index}:fastest_ms".
+// The points depend on the streak, ARGV[10 + streak] being the points of the answer for that streak.
+var submitAnswerScript = redis.NewScript(`
+local window = redis.call("HMGET", KEYS[5], "index", "deadline", "paused", "closed")
+local current = tonumber(window[1])
+if current == nil or current < tonumber(ARGV[2]) then
+	return -3
+end
+if current > tonumber(ARGV[2]) or window[4] == "1" then
+	return -1
+end
+if window[3] == "1" then
+	return -2
+end
+local time = redis.call("TIME")
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+if now > tonumber(window[2]) + tonumber(ARGV[9]) then
+	return -1
+end
+
+if redis.call("HSETNX", KEYS[1], ARGV[2] .. ":" .. ARGV[1], ARGV[3]) == 0 then
+	return false
+end
+local streak = 0
+if ARGV[4] == "1" then
+	local last = tonumber(redis.call("HGET", KEYS[6], ARGV[1]))
+	if last ~= nil and last == tonumber(ARGV[2]) - 1 then
+		streak = tonumber(redis.call("HGET", KEYS[2], ARGV[1])) or 0
+	end
+	streak = streak + 1
+end
+redis.call("HSET", KEYS[2], ARGV[1], streak)
+redis.call("HSET", KEYS[6], ARGV[1], ARGV[2])
+local score = redis.call("ZINCRBY", KEYS[3], ARGV[10 + streak], ARGV[1])
+redis.call("HSET", KEYS[1], ARGV[2] .. ":" .. ARGV[1],
+	table.concat({ARGV[3], ARGV[7], ARGV[4], ARGV[10 + streak], ARGV[8]}, ";"))
+
+local question = ARGV[2] .. ":"
+redis.call("HINCRBY", KEYS[4], question .. "total", 1)
+for option in string.gmatch(ARGV[8], "[^,]+") do
+	redis.call("HINCRBY", KEYS[4], question .. "option:" .. option, 1)
+end
+if ARGV[4] == "1" then
+	redis.call("HINCRBY", KEYS[4], question .. "correct", 1)
+	local fastest = tonumber(redis.call("HGET", KEYS[4], question .. "fastest_ms"))
+	if fastest == nil or tonumber(ARGV[7]) < fastest then
+		redis.call("HSET", KEYS[4], question .. "fastest_ms", ARGV[7], question .. "fastest_user", ARGV[1])
+	end
+end
+
+for _, i in ipairs({1, 2, 3, 4, 6}) do
+	redis.call("EXPIRE", KEYS[i], ARGV[5])
+end
+local rank = redis.call("ZREVRANK", KEYS[3], ARGV[1])
+local top = redis.call("ZREVRANGE", KEYS[3], 0, tonumber(ARGV[6]) - 1, "WITHSCORES")
+return {streak, score, rank + 1, top}
+`)
+
+// SubmitAnswer atomically checks that the user hasn't answered the question yet, updates the streak of the user,
+// adds the points of the answer to the user score and to the answer distribution of the question,
+// and reads the resulting rank and leaderboard, so that a question can only be scored once across the instances
+// and the leaderboard reflects the new score.
+// pointsByStreak are the points of the answer by the resulting streak, which only fully correct answers extend.
+func SubmitAnswer(
+	ctx context.Context, sessionId models.SessionId, username models.Username, questionIndex int,
+	answer *models.SubmittedAnswerInput, count int, expiration time.Duration,
+) (*models.SubmittedAnswer, error) {
+	keys := []string{
+		sessionId.GetAnswersKey(), sessionId.GetStreakKey(), sessionId.GetLeaderboardKey(), sessionId.GetDistributionKey(),
+		sessionId.GetQuestionKey(), sessionId.GetLastAnsweredKey(),
+	}
+	options := lo.Map(answer.Selected, func(option int, index int) string {
+		return strconv.Itoa(option)
+	})
+	args := []any{
+		username.String(), questionIndex, answer.AnsweredAt.UnixMilli(), answer.Correct, int(expiration.Seconds()), count,
+		answer.ResponseTime.Milliseconds(), strings.Join(options, ","), configs.AnswerGracePeriod.Milliseconds(),
+	}
+	for _, points := range answer.PointsByStreak {
+		args = append(args, float64(points))
+	}
+	value, err := submitAnswerScript.Run(ctx, client, keys, args...).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrQuestionAnswered
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch value {
+	case int64(-1):
+		return nil, ErrAnswerTooLate
+	case int64(-2):
+		return nil, ErrQuizPaused
+	case int64(-3):
+		return nil, ErrQuestionNotInProgress
+	}
+	res, ok := value.([]any)
+	if !ok {
+		return nil, fmt.Errorf("unexpected submit answer result: %v", value)
+	}
+	streak, _ := res[0].(int64)
+	score, err := strconv.ParseFloat(fmt.Sprint(res[1]), 64)
+	if err != nil {
+		return nil, err
+	}
+	rank, _ := res[2].(int64)
+	top, _ := res[3].([]any)
+	leaderboard := make([]models.UserScore, 0, len(top)/2)
+	for i := 0; i+1 < len(top); i += 2 {
+		userScore, err := strconv.ParseFloat(fmt.Sprint(top[i+1]), 64)
+		if err != nil {
+			return nil, err
+		}
+		if userScore > 0 {
+			leaderboard = append(leaderboard, models.UserScore{
+				Username: models.Username(fmt.Sprint(top[i])),
+				Score:    models.Score(userScore),
+			})
+		}
+	}
+	return &models.SubmittedAnswer{
+		Streak:      int(streak),
+		NewScore:    models.Score(score),
+		Rank:        rank,
+		Leaderboard: leaderboard,
+	}, nil
+}
+
+// GetQuestionResults returns the answer distribution of a question with the given number of options.
+func GetQuestionResults(
+	ctx context.Context, sessionId models.SessionId, questionIndex int, optionCount int,
+) (*models.QuestionResults, error) {
+	question := fmt.Sprintf("%d:", questionIndex)
+	fields := []string{question + "total", question + "correct", question + "fastest_user", question + "fastest_ms"}
+	for i := range optionCount {
+		fields = append(fields, fmt.Sprintf("%soption:%d", question, i))
+	}
+	values, err := client.HMGet(ctx, sessionId.GetDistributionKey(), fields...).Result()
+	if err != nil {
+		return nil, err
+	}
+	count := func(value any) int64 {
+		n, _ := strconv.ParseInt(fmt.Sprint(value), 10, 64)
+		return n
+	}
+	results := &models.QuestionResults{
+		QuestionIndex:  questionIndex,
+		Histogram:      make([]int64, optionCount),
+		Answers:        count(values[0]),
+		CorrectAnswers: count(values[1]),
+	}
+	if username, ok := values[2].(string); ok {
+		results.Fastest = &models.FastestAnswer{
+			Username:       models.Username(username),
+			ResponseTimeMs: count(values[3]),
+		}
+	}
+	for i := range optionCount {
+		results.Histogram[i] = count(values[4+i])
+	}
+	return results, nil
+}
+
+// HasAnswered returns whether the user has already answered the question.
+func HasAnswered(ctx context.Context, sessionId models.SessionId, username models.Username, questionIndex int) (bool, error) {
+	field := fmt.Sprintf("%d:%s", questionIndex, username)
+	return client.HExists(ctx, sessionId.GetAnswersKey(), field).Result()
+}
+
+// GetAnswers returns all the answers recorded in the session, by question and answer time.
+func GetAnswers(ctx context.Context, sessionId models.SessionId) ([]models.AnswerResult, error) {
+	values, err := client.HGetAll(ctx, sessionId.GetAnswersKey()).Result()
+	if err != nil {
+		return nil, err
+	}
+	answers := make([]models.AnswerResult, 0, len(values))
+	for field, value := range values {
+		question, username, _ := strings.Cut(field, ":")
+		questionIndex, err := strconv.Atoi(question)
+		if err != nil {
+			return nil, fmt.Errorf("invalid answer field %q: %w", field, err)
+		}
+		parts := strings.Split(value, ";")
+		if len(parts) != 5 {
+			return nil, fmt.Errorf("invalid answer %q: %q", field, value)
+		}
+		answeredAt, _ := strconv.ParseInt(parts[0], 10, 64)
+		responseTime, _ := strconv.ParseInt(parts[1], 10, 64)
+		points, _ := strconv.ParseFloat(parts[3], 64)
+		answer := models.AnswerResult{
+			QuestionIndex:  questionIndex,
+			Username:       models.Username(username),
+			AnsweredAt:     time.UnixMilli(answeredAt),
+			ResponseTimeMs: responseTime,
+			Correct:        parts[2] == "1",
+			Points:         models.Score(points),
+		}
+		for _, option := range strings.Split(parts[4], ",") {
+			if n, err := strconv.Atoi(option); err == nil {
+				answer.Selected = append(answer.Selected, n)
+			}
+		}
+		answers = append(answers, answer)
+	}
+	slices.SortFunc(answers, func(a, b models.AnswerResult) int {
+		if a.QuestionIndex != b.QuestionIndex {
+			return a.QuestionIndex - b.QuestionIndex
+		}
+		return a.AnsweredAt.Compare(b.AnsweredAt)
+	})
+	return answers, nil
+}
+
+// GetUserStreak returns the streak of a user as of the last question the user answered.
+func GetUserStreak(ctx context.Context, sessionId models.SessionId, username models.Username) (int, error) {
+	streak, err := client.HGet(ctx, sessionId.GetStreakKey(), username.String()).Int()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return streak, err
+}
+
+// GetUserScore returns the score of a user, 0 if the user hasn't scored yet.
+func GetUserScore(ctx context.Context, sessionId models.SessionId, username models.Username) (models.Score, error) {
+	score, err := client.ZScore(ctx, sessionId.GetLeaderboardKey(), username.String()).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	return models.Score(score), err
 }
 
 // GetLeaderboard retrieves the top N players from the leaderboard.
-func GetLeaderboard(ctx context.Context, quizId models.QuizId, count int) ([]models.UserScore, error) {
-	zres, err := client.ZRevRangeWithScores(ctx, quizId.GetLeaderboardKey(), 0, int64(count-1)).Result()
+func GetLeaderboard(ctx context.Context, sessionId models.SessionId, count int) ([]models.UserScore, error) {
+	zres, err := client.ZRevRangeWithScores(ctx, sessionId.GetLeaderboardKey(), 0, int64(count-1)).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -102,25 +710,110 @@ func GetLeaderboard(ctx context.Context, quizId models.QuizId, count int) ([]mod
 	return res, nil
 }
 
-func CleanUpUserScores(ctx context.Context, quizId models.QuizId) error {
-	fmt.Println("cleaning up user scores of quiz:", quizId)
-	err := client.Del(ctx, quizId.GetLeaderboardKey()).Err()
+func CleanUpUserScores(ctx context.Context, sessionId models.SessionId) error {
+	fmt.Println("cleaning up user scores of session:", sessionId)
+	err := client.Del(ctx, sessionId.GetLeaderboardKey(), sessionId.GetStreakKey(), sessionId.GetLastAnsweredKey(),
+		sessionId.GetAnswersKey(), sessionId.GetDistributionKey()).Err()
 	if err != nil {
 		return fmt.Errorf("error deleting leaderboard: %w", err)
 	}
 	return nil
 }
 
-func GetPlayerRank(ctx context.Context, quizId models.QuizId, username models.Username) (rank int64, score float64, err error) {
-	score, err = client.ZScore(ctx, quizId.GetLeaderboardKey(), username.String()).Result()
+// ExpireUserScores keeps the leaderboard, streaks and answers of an ended session for the given retention,
+// so that the instances can still send the players their final rank.
+func ExpireUserScores(ctx context.Context, sessionId models.SessionId, retention time.Duration) error {
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range []string{
+			sessionId.GetLeaderboardKey(), sessionId.GetStreakKey(), sessionId.GetLastAnsweredKey(), sessionId.GetAnswersKey(),
+			sessionId.GetDistributionKey(),
+		} {
+			pipe.Expire(ctx, key, retention)
+		}
+		return nil
+	})
 	if err != nil {
-		return 0, 0, err
+		return fmt.Errorf("error expiring leaderboard: %w", err)
 	}
+	return nil
+}
 
-	rank, err = client.ZRevRank(ctx, quizId.GetLeaderboardKey(), username.String()).Result()
+// GetFinalScores returns the scores of all the participants of the session, including those who scored nothing,
+// in leaderboard order.
+func GetFinalScores(ctx context.Context, sessionId models.SessionId) ([]models.UserScore, error) {
+	res, err := client.ZRevRangeWithScores(ctx, sessionId.GetLeaderboardKey(), 0, -1).Result()
 	if err != nil {
-		return 0, 0, err
+		return nil, err
+	}
+	scores := make([]models.UserScore, 0, len(res))
+	for _, z := range res {
+		scores = append(scores, models.UserScore{
+			Username: models.Username(fmt.Sprint(z.Member)),
+			Score:    models.Score(z.Score),
+		})
+	}
+	return scores, nil
+}
+
+// GetPlayerRanks returns the rank of the given users in 2 round trips whatever their number:
+// the ranks and scores are read in a first pipeline, and the players around each user in a second one.
+// Users not in the leaderboard are omitted.
+func GetPlayerRanks(
+	ctx context.Context, sessionId models.SessionId, usernames []models.Username,
+) (map[models.Username]*models.PlayerRank, error) {
+	key := sessionId.GetLeaderboardKey()
+	var total *redis.IntCmd
+	ranks := make([]*redis.IntCmd, len(usernames))
+	scores := make([]*redis.FloatCmd, len(usernames))
+	_, err := client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		total = pipe.ZCard(ctx, key)
+		for i, username := range usernames {
+			ranks[i] = pipe.ZRevRank(ctx, key, username.String())
+			scores[i] = pipe.ZScore(ctx, key, username.String())
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, err
 	}
 
-	return rank + 1, score, nil
+	res := map[models.Username]*models.PlayerRank{}
+	neighbours := map[models.Username]*redis.ZSliceCmd{}
+	_, err = client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, username := range usernames {
+			rank, err := ranks[i].Result()
+			if err != nil {
+				continue
+			}
+			res[username] = &models.PlayerRank{
+				Rank:         rank + 1,
+				Score:        models.Score(scores[i].Val()),
+				TotalPlayers: total.Val(),
+			}
+			neighbours[username] = pipe.ZRevRangeWithScores(ctx, key, max(0, rank-1), rank+1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for username, cmd := range neighbours {
+		playerRank := res[username]
+		// the range starts at the player above, unless the user is first
+		rank := playerRank.Rank - 1
+		start := max(0, rank-1)
+		for i, item := range cmd.Val() {
+			userScore := &models.UserScore{
+				Username: models.Username(item.Member.(string)),
+				Score:    models.Score(item.Score),
+			}
+			switch start + int64(i) {
+			case rank - 1:
+				playerRank.Above = userScore
+			case rank + 1:
+				playerRank.Below = userScore
+			}
+		}
+	}
+	return res, nil
 }
@@ -0,0 +1,256 @@
+package datastore
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"quiz/configs"
+	"quiz/core/models"
+)
+
+// startRedis points the datastore to an in-memory Redis for the duration of the test.
+func startRedis(t *testing.T) *miniredis.Miniredis {
+	mr := miniredis.RunT(t)
+	previous := client
+	client = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		client.Close()
+		client = previous
+	})
+	return mr
+}
+
+// startQuestion opens the answer window of the question until the deadline.
+func startQuestion(t *testing.T, sessionId models.SessionId, questionIndex int, deadline time.Time) {
+	window := &models.QuestionWindow{
+		QuestionIndex: questionIndex,
+		StartedAt:     deadline.Add(-10 * time.Second),
+		Deadline:      deadline,
+	}
+	if err := SaveQuestionWindow(context.Background(), sessionId, window); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// submitAnswer records an answer given the response time, worth 100 points plus 10 by streak if correct.
+func submitAnswer(
+	sessionId models.SessionId, username models.Username, questionIndex int, correct bool, responseTime time.Duration,
+	selected ...int,
+) (*models.SubmittedAnswer, error) {
+	pointsByStreak := make([]models.Score, questionIndex+2)
+	for streak := range pointsByStreak {
+		if correct {
+			pointsByStreak[streak] = models.Score(100 + 10*streak)
+		}
+	}
+	input := &models.SubmittedAnswerInput{
+		AnsweredAt:     time.Now(),
+		ResponseTime:   responseTime,
+		Correct:        correct,
+		Selected:       selected,
+		PointsByStreak: pointsByStreak,
+	}
+	return SubmitAnswer(context.Background(), sessionId, username, questionIndex, input, 5, time.Minute)
+}
+
+func TestSubmitAnswerOnce(t *testing.T) {
+	startRedis(t)
+	sessionId := models.NewSessionId(1, 1)
+	startQuestion(t, sessionId, 0, time.Now().Add(time.Minute))
+
+	submitted, err := submitAnswer(sessionId, "alice", 0, true, time.Second, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if submitted.NewScore != 110 || submitted.Rank != 1 {
+		t.Fatalf("expected score 110 at rank 1, got %v at rank %d", submitted.NewScore, submitted.Rank)
+	}
+	// the answer is recorded in Redis, so a second answer is rejected whichever instance receives it
+	if _, err = submitAnswer(sessionId, "alice", 0, true, time.Second, 1); !errors.Is(err, ErrQuestionAnswered) {
+		t.Fatalf("expected ErrQuestionAnswered, got %v", err)
+	}
+	score, err := GetUserScore(context.Background(), sessionId, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 110 {
+		t.Fatalf("expected the score to be counted once, got %v", score)
+	}
+}
+
+func TestSubmitAnswerStreak(t *testing.T) {
+	startRedis(t)
+	sessionId := models.NewSessionId(1, 1)
+	answers := []struct {
+		questionIndex int
+		correct       bool
+		streak        int
+	}{
+		{0, true, 1},
+		{1, true, 2},
+		{2, false, 0}, // a wrong answer resets the streak
+		{3, true, 1},
+		{4, true, 2},
+		{6, true, 1}, // so does a missed question
+	}
+	for _, answer := range answers {
+		startQuestion(t, sessionId, answer.questionIndex, time.Now().Add(time.Minute))
+		submitted, err := submitAnswer(sessionId, "alice", answer.questionIndex, answer.correct, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if submitted.Streak != answer.streak {
+			t.Fatalf("question %d: expected streak %d, got %d", answer.questionIndex, answer.streak, submitted.Streak)
+		}
+	}
+	// the streak is scored, 100 points plus 10 by streak for each correct answer
+	score, err := GetUserScore(context.Background(), sessionId, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if score != 110+120+110+120+110 {
+		t.Fatalf("expected score 570, got %v", score)
+	}
+}
+
+func TestGetPlayerRanks(t *testing.T) {
+	startRedis(t)
+	ctx := context.Background()
+	sessionId := models.NewSessionId(1, 1)
+	client.ZAdd(ctx, sessionId.GetLeaderboardKey(),
+		redis.Z{Member: "alice", Score: 300}, redis.Z{Member: "bob", Score: 200}, redis.Z{Member: "carol", Score: 100})
+
+	ranks, err := GetPlayerRanks(ctx, sessionId, []models.Username{"alice", "bob", "carol", "dave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ranks["dave"]; ok {
+		t.Fatal("expected a user outside the leaderboard to be omitted")
+	}
+	alice, bob, carol := &models.UserScore{Username: "alice", Score: 300}, &models.UserScore{Username: "bob", Score: 200},
+		&models.UserScore{Username: "carol", Score: 100}
+	expected := map[models.Username]*models.PlayerRank{
+		// nobody above the first player, nobody below the last one
+		"alice": {Rank: 1, Score: 300, TotalPlayers: 3, Below: bob},
+		"bob":   {Rank: 2, Score: 200, TotalPlayers: 3, Above: alice, Below: carol},
+		"carol": {Rank: 3, Score: 100, TotalPlayers: 3, Above: bob},
+	}
+	if !reflect.DeepEqual(ranks, expected) {
+		for username, rank := range ranks {
+			t.Logf("%s: %+v above %+v below %+v", username, rank, rank.Above, rank.Below)
+		}
+		t.Fatal("unexpected ranks")
+	}
+}
+
+func TestGetQuestionResults(t *testing.T) {
+	startRedis(t)
+	sessionId := models.NewSessionId(1, 1)
+	startQuestion(t, sessionId, 0, time.Now().Add(time.Minute))
+	answers := []struct {
+		username     models.Username
+		correct      bool
+		responseTime time.Duration
+		selected     []int
+	}{
+		{"alice", true, 1200 * time.Millisecond, []int{1}},
+		{"bob", false, 800 * time.Millisecond, []int{0, 2}}, // the fastest answer is wrong
+		{"carol", true, 900 * time.Millisecond, []int{1}},
+	}
+	for _, answer := range answers {
+		if _, err := submitAnswer(sessionId, answer.username, 0, answer.correct, answer.responseTime, answer.selected...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := GetQuestionResults(context.Background(), sessionId, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(results.Histogram, []int64{1, 2, 1, 0}) {
+		t.Fatalf("expected histogram [1 2 1 0], got %v", results.Histogram)
+	}
+	if results.Answers != 3 || results.CorrectAnswers != 2 {
+		t.Fatalf("expected 2 correct answers out of 3, got %d out of %d", results.CorrectAnswers, results.Answers)
+	}
+	expectedFastest := &models.FastestAnswer{Username: "carol", ResponseTimeMs: 900}
+	if !reflect.DeepEqual(results.Fastest, expectedFastest) {
+		t.Fatalf("expected fastest %+v, got %+v", expectedFastest, results.Fastest)
+	}
+}
+
+func TestSubmitAnswerGracePeriod(t *testing.T) {
+	mr := startRedis(t)
+	sessionId := models.NewSessionId(1, 1)
+	deadline := time.Now().Truncate(time.Millisecond)
+	startQuestion(t, sessionId, 0, deadline)
+
+	// the answer script checks the deadline with the Redis clock
+	mr.SetTime(deadline.Add(configs.AnswerGracePeriod / 2))
+	if _, err := submitAnswer(sessionId, "alice", 0, true, time.Second); err != nil {
+		t.Fatalf("expected an answer within the grace period to be accepted, got %v", err)
+	}
+	mr.SetTime(deadline.Add(2 * configs.AnswerGracePeriod))
+	if _, err := submitAnswer(sessionId, "bob", 0, true, time.Second); !errors.Is(err, ErrAnswerTooLate) {
+		t.Fatalf("expected ErrAnswerTooLate after the grace period, got %v", err)
+	}
+}
+
+func TestRemoveParticipantAfterResume(t *testing.T) {
+	startRedis(t)
+	ctx := context.Background()
+	sessionId := models.NewSessionId(1, 1)
+	if err := AddParticipant(ctx, sessionId, "alice", "first", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	// the user resumes the session with another socket before the first one is known to be disconnected
+	if err := AddParticipant(ctx, sessionId, "alice", "second", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	removed, err := RemoveParticipant(ctx, sessionId, "alice", "first")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed {
+		t.Fatal("expected the user bound to another socket to stay a participant")
+	}
+	if removed, err = RemoveParticipant(ctx, sessionId, "alice", "second"); err != nil || !removed {
+		t.Fatalf("expected the user to be removed, got %v %v", removed, err)
+	}
+	count, err := CountParticipants(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Fatalf("expected no participant, got %d", count)
+	}
+}
+
+func TestClearParticipantsDisconnected(t *testing.T) {
+	startRedis(t)
+	ctx := context.Background()
+	sessionId := models.NewSessionId(1, 1)
+	for _, username := range []models.Username{"alice", "bob"} {
+		if err := MarkUserAsInQuiz(ctx, sessionId, username, username.String(), time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// bob is disconnected but can still resume the session
+	if _, err := RemoveParticipant(ctx, sessionId, "bob", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ClearParticipants(ctx, sessionId); err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []models.Username{"alice", "bob"} {
+		if err := CheckUserInQuiz(ctx, sessionId, username); !errors.Is(err, ErrUserNotInQuiz) {
+			t.Fatalf("expected %s not to be in the quiz, got %v", username, err)
+		}
+	}
+}
@@ -2,7 +2,7 @@ package event_publisher
 
 import (
 	"encoding/json"
-	"log"
+	"sync"
 
 	"github.com/IBM/sarama"
 	"quiz/configs"
@@ -10,19 +10,29 @@ import (
 
 var producer sarama.SyncProducer
 
-func init() {
+var producerMutex sync.Mutex
+
+// Connect starts the Kafka producer, which is otherwise started by the first Publish,
+// so that the packages publishing events can be loaded without a broker, eg in tests.
+func Connect() error {
+	producerMutex.Lock()
+	defer producerMutex.Unlock()
+	if producer != nil {
+		return nil
+	}
 	config := sarama.NewConfig()
 	config.Producer.Partitioner = sarama.NewRandomPartitioner
 	config.Producer.RequiredAcks = sarama.WaitForAll
 	config.Producer.Return.Successes = true
 	var err error
 	producer, err = sarama.NewSyncProducer(configs.KafkaBrokerAddress, config)
-	if err != nil {
-		log.Fatalln("Failed to start Sarama producer:", err)
-	}
+	return err
 }
 
 func Publish(topic string, key string, data any) error {
+	if err := Connect(); err != nil {
+		return err
+	}
 	msgValue, err := json.Marshal(data)
 	if err != nil {
 		return err
@@ -1,23 +1,46 @@
 package main
 
 import (
+	"log"
+
 	"quiz/configs"
 	"quiz/consumers"
 	"quiz/core/managers"
+	"quiz/event_publisher"
+	"quiz/repository"
 	"quiz/websocket"
 	"quiz/websocket/socket"
 	"quiz/workflow"
 )
 
 func main() {
+	if err := configs.CheckSecrets(); err != nil {
+		log.Fatalln(err)
+	}
 	c := workflow.StartWorkflowClient()
 	defer c.Close()
+	if err := event_publisher.Connect(); err != nil {
+		log.Fatalln("Failed to start Sarama producer:", err)
+	}
+
+	quizRepository, err := repository.NewQuizRepository()
+	if err != nil {
+		log.Fatalln("unable to create quiz repository:", err)
+	}
+
+	resultsRepository, err := repository.NewResultsRepository()
+	if err != nil {
+		log.Fatalln("unable to create results repository:", err)
+	}
 
-	quizSessionManager := managers.NewQuizSessionManager()
+	quizSessionManager := managers.NewQuizSessionManager(quizRepository)
+	quizContentManager := managers.NewQuizContentManager(quizRepository)
+	quizResultsManager := managers.NewQuizResultsManager(resultsRepository)
 
 	consumers.Consume(configs.QuizProgressedTopic, consumers.NewQuizProgressedEventHandler(quizSessionManager))
 	consumers.Consume(configs.ScoreUpdatedTopic, consumers.NewScoreUpdatedEventHandler(quizSessionManager))
+	consumers.Consume(configs.ParticipantChangedTopic, consumers.NewParticipantChangedEventHandler(quizSessionManager))
 
 	server := socket.StartServer()
-	websocket.ListenAndHandleEvent(quizSessionManager, server)
+	websocket.ListenAndHandleEvent(quizSessionManager, quizContentManager, quizResultsManager, server)
 }
@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"quiz/core/data"
+	"quiz/core/models"
+	"quiz/core/questions"
+)
+
+// FileQuizRepository reads quizzes from a directory of JSON/YAML files, one quiz per file.
+// The directory is scanned on every lookup so new or edited files are picked up without a restart.
+type FileQuizRepository struct {
+	dir   string
+	cache map[string]cachedQuizFile
+	mutex sync.Mutex
+}
+
+type cachedQuizFile struct {
+	modTime time.Time
+	quiz    *models.Quiz
+}
+
+func NewFileQuizRepository(dir string) (*FileQuizRepository, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening quiz directory: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("quiz store path is not a directory: %s", dir)
+	}
+	return &FileQuizRepository{
+		dir:   dir,
+		cache: map[string]cachedQuizFile{},
+	}, nil
+}
+
+func (r *FileQuizRepository) GetQuiz(ctx context.Context, quizId models.QuizId) (*models.Quiz, error) {
+	quizzes, err := r.loadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, quiz := range quizzes {
+		if quiz.Id == quizId {
+			return quiz, nil
+		}
+	}
+	return nil, data.ErrQuizNotFound
+}
+
+func (r *FileQuizRepository) ListQuizzes(ctx context.Context) ([]*models.Quiz, error) {
+	return r.loadAll()
+}
+
+func (r *FileQuizRepository) CreateQuiz(ctx context.Context, quiz *models.Quiz) (*models.Quiz, error) {
+	quizzes, err := r.loadAll()
+	if err != nil {
+		return nil, err
+	}
+	var maxId models.QuizId
+	for _, existing := range quizzes {
+		maxId = max(maxId, existing.Id)
+	}
+	created := *quiz
+	// the file of the ID is created only if it does not exist, so that the quizzes created concurrently,
+	// possibly by other instances sharing the directory, take the next IDs
+	for created.Id = maxId + 1; ; created.Id++ {
+		err = createQuizFile(filepath.Join(r.dir, fmt.Sprintf("%d.json", created.Id)), &created)
+		if !errors.Is(err, fs.ErrExist) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (r *FileQuizRepository) UpdateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	path, err := r.findPath(quiz.Id)
+	if err != nil {
+		return err
+	}
+	return writeQuizFile(path, quiz)
+}
+
+func (r *FileQuizRepository) DeleteQuiz(ctx context.Context, quizId models.QuizId) error {
+	path, err := r.findPath(quizId)
+	if err != nil {
+		return err
+	}
+	if err = os.Remove(path); err != nil {
+		return fmt.Errorf("error deleting quiz file: %w", err)
+	}
+	return nil
+}
+
+// findPath returns the path of the file containing the quiz.
+func (r *FileQuizRepository) findPath(quizId models.QuizId) (string, error) {
+	if _, err := r.loadAll(); err != nil {
+		return "", err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for path, cached := range r.cache {
+		if cached.quiz.Id == quizId {
+			return path, nil
+		}
+	}
+	return "", data.ErrQuizNotFound
+}
+
+func (r *FileQuizRepository) loadAll() ([]*models.Quiz, error) {
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading quiz directory: %w", err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	var res []*models.Quiz
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isQuizFile(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(r.dir, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		seen[path] = true
+		cached, ok := r.cache[path]
+		if !ok || !cached.modTime.Equal(info.ModTime()) {
+			quiz, err := readQuizFile(path)
+			if err != nil {
+				return nil, err
+			}
+			cached = cachedQuizFile{modTime: info.ModTime(), quiz: quiz}
+			r.cache[path] = cached
+		}
+		res = append(res, cached.quiz)
+	}
+	for path := range r.cache {
+		if !seen[path] {
+			delete(r.cache, path)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Id < res[j].Id
+	})
+	return res, nil
+}
+
+func isQuizFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+// writeQuizFile writes the quiz in the format given by the file extension.
+func writeQuizFile(path string, quiz *models.Quiz) error {
+	content, err := encodeQuizFile(path, quiz)
+	if err != nil {
+		return err
+	}
+	if err = os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("error writing quiz file: %w", err)
+	}
+	return nil
+}
+
+// createQuizFile writes the quiz to a new file, failing with fs.ErrExist if the file exists.
+// The quiz is written to a temporary file first, so that the file is never read partially written.
+func createQuizFile(path string, quiz *models.Quiz) error {
+	content, err := encodeQuizFile(path, quiz)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".quiz-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error writing quiz file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error writing quiz file: %w", err)
+	}
+	if err = os.Chmod(tmp.Name(), 0644); err != nil {
+		return fmt.Errorf("error writing quiz file: %w", err)
+	}
+	// unlike a rename, a link fails if the file exists
+	if err = os.Link(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error creating quiz file: %w", err)
+	}
+	return nil
+}
+
+func encodeQuizFile(path string, quiz *models.Quiz) ([]byte, error) {
+	content, err := json.MarshalIndent(quiz, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var doc any
+		if err = json.Unmarshal(content, &doc); err != nil {
+			return nil, err
+		}
+		if content, err = yaml.Marshal(doc); err != nil {
+			return nil, err
+		}
+	}
+	return content, nil
+}
+
+func readQuizFile(path string) (*models.Quiz, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// YAML files are converted to JSON so the models only need the json tags
+	if ext := strings.ToLower(filepath.Ext(path)); ext == ".yaml" || ext == ".yml" {
+		var doc any
+		if err = yaml.Unmarshal(content, &doc); err != nil {
+			return nil, fmt.Errorf("error parsing quiz file %s: %w", path, err)
+		}
+		if content, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("error parsing quiz file %s: %w", path, err)
+		}
+	}
+	quiz := &models.Quiz{}
+	if err = json.Unmarshal(content, quiz); err != nil {
+		return nil, fmt.Errorf("error parsing quiz file %s: %w", path, err)
+	}
+	// the files are edited by hand, so they are checked as the quizzes created through the API
+	if err = questions.ValidateQuiz(quiz); err != nil {
+		return nil, fmt.Errorf("error loading quiz file %s: %w", path, err)
+	}
+	return quiz, nil
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+
+	"quiz/core/data"
+	"quiz/core/models"
+)
+
+func TestFileQuizRepository(t *testing.T) {
+	repo, err := NewFileQuizRepository("../quizzes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	quizzes, err := repo.ListQuizzes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quizzes) != len(data.QuizData) {
+		t.Fatalf("expected %d quizzes, got %d", len(data.QuizData), len(quizzes))
+	}
+	for _, quiz := range quizzes {
+		expected := data.QuizData[quiz.Id]
+		if expected == nil {
+			t.Fatalf("unexpected quiz %d", quiz.Id)
+		}
+		if len(quiz.Questions) != len(expected.Questions) {
+			t.Fatalf("quiz %d: expected %d questions, got %d", quiz.Id, len(expected.Questions), len(quiz.Questions))
+		}
+		for i, question := range quiz.Questions {
+			if !reflect.DeepEqual(question, expected.Questions[i]) {
+				t.Errorf("quiz %d question %d: expected %+v, got %+v", quiz.Id, i, expected.Questions[i], question)
+			}
+		}
+	}
+
+	if _, err = repo.GetQuiz(context.Background(), 42); !errors.Is(err, data.ErrQuizNotFound) {
+		t.Errorf("expected ErrQuizNotFound, got %v", err)
+	}
+}
+
+func TestFileQuizRepositoryCreateConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	// instances sharing the directory each have their own repository
+	repos := make([]*FileQuizRepository, 4)
+	for i := range repos {
+		repo, err := NewFileQuizRepository(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repos[i] = repo
+	}
+	ids := make([]models.QuizId, len(repos))
+	var wg sync.WaitGroup
+	for i, repo := range repos {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			created, err := repo.CreateQuiz(context.Background(), data.QuizData[1])
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids[i] = created.Id
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(ids)
+	if !reflect.DeepEqual(ids, []models.QuizId{1, 2, 3, 4}) {
+		t.Fatalf("expected the quizzes to take distinct IDs, got %v", ids)
+	}
+	quizzes, err := repos[0].ListQuizzes(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quizzes) != len(ids) {
+		t.Fatalf("expected %d quizzes, got %d", len(ids), len(quizzes))
+	}
+}
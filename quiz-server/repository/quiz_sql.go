@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"quiz/core/data"
+	"quiz/core/models"
+	"quiz/core/questions"
+)
+
+// SqlQuizRepository stores quizzes in a SQL database (SQLite or Postgres).
+// A quiz is always loaded as a whole, so its questions are stored as a JSON document next to the quiz ID.
+type SqlQuizRepository struct {
+	db *sql.DB
+}
+
+func NewSqlQuizRepository(db *sql.DB, driver string) (*SqlQuizRepository, error) {
+	schema := `CREATE TABLE IF NOT EXISTS quizzes (
+		id INTEGER PRIMARY KEY,
+		definition TEXT NOT NULL
+	)`
+	if driver == "postgres" {
+		schema = `CREATE TABLE IF NOT EXISTS quizzes (
+			id SERIAL PRIMARY KEY,
+			definition TEXT NOT NULL
+		)`
+	}
+	if _, err := db.Exec(schema); err != nil {
+		return nil, fmt.Errorf("error creating quizzes table: %w", err)
+	}
+	return &SqlQuizRepository{db: db}, nil
+}
+
+func (r *SqlQuizRepository) GetQuiz(ctx context.Context, quizId models.QuizId) (*models.Quiz, error) {
+	var definition string
+	err := r.db.QueryRowContext(ctx, `SELECT definition FROM quizzes WHERE id = $1`, int(quizId)).Scan(&definition)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, data.ErrQuizNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeQuiz(quizId, definition)
+}
+
+func (r *SqlQuizRepository) ListQuizzes(ctx context.Context) ([]*models.Quiz, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, definition FROM quizzes ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var res []*models.Quiz
+	for rows.Next() {
+		var id int
+		var definition string
+		if err = rows.Scan(&id, &definition); err != nil {
+			return nil, err
+		}
+		quiz, err := decodeQuiz(models.QuizId(id), definition)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, quiz)
+	}
+	return res, rows.Err()
+}
+
+func (r *SqlQuizRepository) CreateQuiz(ctx context.Context, quiz *models.Quiz) (*models.Quiz, error) {
+	definition, err := json.Marshal(quiz)
+	if err != nil {
+		return nil, err
+	}
+	var id int
+	err = r.db.QueryRowContext(ctx, `INSERT INTO quizzes (definition) VALUES ($1) RETURNING id`, string(definition)).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("error inserting quiz: %w", err)
+	}
+	created := *quiz
+	created.Id = models.QuizId(id)
+	return &created, nil
+}
+
+func (r *SqlQuizRepository) UpdateQuiz(ctx context.Context, quiz *models.Quiz) error {
+	definition, err := json.Marshal(quiz)
+	if err != nil {
+		return err
+	}
+	res, err := r.db.ExecContext(ctx, `UPDATE quizzes SET definition = $1 WHERE id = $2`, string(definition), int(quiz.Id))
+	if err != nil {
+		return fmt.Errorf("error updating quiz: %w", err)
+	}
+	return checkAffected(res)
+}
+
+func (r *SqlQuizRepository) DeleteQuiz(ctx context.Context, quizId models.QuizId) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM quizzes WHERE id = $1`, int(quizId))
+	if err != nil {
+		return fmt.Errorf("error deleting quiz: %w", err)
+	}
+	return checkAffected(res)
+}
+
+func checkAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return data.ErrQuizNotFound
+	}
+	return nil
+}
+
+func decodeQuiz(quizId models.QuizId, definition string) (*models.Quiz, error) {
+	quiz := &models.Quiz{}
+	if err := json.Unmarshal([]byte(definition), quiz); err != nil {
+		return nil, fmt.Errorf("error parsing quiz %d: %w", quizId, err)
+	}
+	quiz.Id = quizId
+	if err := questions.ValidateQuiz(quiz); err != nil {
+		return nil, fmt.Errorf("error loading quiz %d: %w", quizId, err)
+	}
+	return quiz, nil
+}
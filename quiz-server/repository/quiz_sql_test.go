@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"quiz/core/data"
+	"quiz/core/models"
+)
+
+func TestSqlQuizRepository(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// each connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+	repo, err := NewSqlQuizRepository(db, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	var created []*models.Quiz
+	for _, quizId := range []models.QuizId{1, 2} {
+		quiz, err := repo.CreateQuiz(ctx, data.QuizData[quizId])
+		if err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, quiz)
+	}
+	if created[0].Id != 1 || created[1].Id != 2 {
+		t.Fatalf("expected quizzes 1 and 2, got %d and %d", created[0].Id, created[1].Id)
+	}
+	quizzes, err := repo.ListQuizzes(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quizzes, created) {
+		t.Fatalf("expected the created quizzes, got %+v", quizzes)
+	}
+
+	updated := *created[0]
+	updated.PartialCredit = !updated.PartialCredit
+	if err = repo.UpdateQuiz(ctx, &updated); err != nil {
+		t.Fatal(err)
+	}
+	quiz, err := repo.GetQuiz(ctx, updated.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quiz, &updated) {
+		t.Fatalf("expected %+v, got %+v", updated, quiz)
+	}
+
+	if err = repo.DeleteQuiz(ctx, updated.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = repo.GetQuiz(ctx, updated.Id); !errors.Is(err, data.ErrQuizNotFound) {
+		t.Fatalf("expected ErrQuizNotFound, got %v", err)
+	}
+	if err = repo.DeleteQuiz(ctx, updated.Id); !errors.Is(err, data.ErrQuizNotFound) {
+		t.Fatalf("expected ErrQuizNotFound deleting twice, got %v", err)
+	}
+	if err = repo.UpdateQuiz(ctx, &updated); !errors.Is(err, data.ErrQuizNotFound) {
+		t.Fatalf("expected ErrQuizNotFound updating a deleted quiz, got %v", err)
+	}
+}
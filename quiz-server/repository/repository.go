@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+	"quiz/configs"
+	"quiz/core/data"
+)
+
+// NewQuizRepository creates the quiz content repository selected by configs.QuizStore.
+func NewQuizRepository() (data.QuizRepository, error) {
+	switch configs.QuizStore {
+	case "memory":
+		return data.NewInMemoryQuizRepository(data.QuizData), nil
+	case "file":
+		return NewFileQuizRepository(configs.QuizStoreDir)
+	case "sql":
+		db, err := OpenDatabase()
+		if err != nil {
+			return nil, err
+		}
+		return NewSqlQuizRepository(db, configs.DatabaseDriver)
+	default:
+		return nil, fmt.Errorf("unknown quiz store: %s", configs.QuizStore)
+	}
+}
+
+// NewResultsRepository creates the session results repository selected by configs.ResultsStore.
+// There is no in-memory store, as the results saved by the workflow worker are served by the other processes.
+func NewResultsRepository() (data.ResultsRepository, error) {
+	switch configs.ResultsStore {
+	case "sql":
+		db, err := OpenDatabase()
+		if err != nil {
+			return nil, err
+		}
+		return NewSqlResultsRepository(db)
+	default:
+		return nil, fmt.Errorf("unknown results store: %s", configs.ResultsStore)
+	}
+}
+
+// OpenDatabase connects to the SQL database configured by configs.DatabaseDriver and configs.DatabaseDSN.
+func OpenDatabase() (*sql.DB, error) {
+	var driverName string
+	switch configs.DatabaseDriver {
+	case "sqlite":
+		driverName = "sqlite"
+	case "postgres":
+		driverName = "postgres"
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", configs.DatabaseDriver)
+	}
+	db, err := sql.Open(driverName, configs.DatabaseDSN)
+	if err != nil {
+		return nil, err
+	}
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	return db, nil
+}
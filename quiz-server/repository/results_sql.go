@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"quiz/core/data"
+	"quiz/core/models"
+)
+
+// SqlResultsRepository stores the results of the ended sessions in a SQL database (SQLite or Postgres):
+// a row per session, a row per participant with its final score and rank, and a row per answer.
+// The times are stored as unix milliseconds.
+type SqlResultsRepository struct {
+	db *sql.DB
+}
+
+func NewSqlResultsRepository(db *sql.DB) (*SqlResultsRepository, error) {
+	schema := []string{
+		`CREATE TABLE IF NOT EXISTS session_results (
+			session_id TEXT PRIMARY KEY,
+			quiz_id INTEGER NOT NULL,
+			ended_at BIGINT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS player_results (
+			session_id TEXT NOT NULL,
+			username TEXT NOT NULL,
+			rank INTEGER NOT NULL,
+			score DOUBLE PRECISION NOT NULL,
+			PRIMARY KEY (session_id, username)
+		)`,
+		`CREATE TABLE IF NOT EXISTS answer_results (
+			session_id TEXT NOT NULL,
+			question_index INTEGER NOT NULL,
+			username TEXT NOT NULL,
+			answered_at BIGINT NOT NULL,
+			response_time_ms BIGINT NOT NULL,
+			correct BOOLEAN NOT NULL,
+			points DOUBLE PRECISION NOT NULL,
+			selected TEXT NOT NULL,
+			PRIMARY KEY (session_id, question_index, username)
+		)`,
+	}
+	for _, statement := range schema {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, fmt.Errorf("error creating results tables: %w", err)
+		}
+	}
+	return &SqlResultsRepository{db: db}, nil
+}
+
+func (r *SqlResultsRepository) SaveResults(ctx context.Context, results *models.SessionResults) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, table := range []string{"session_results", "player_results", "answer_results"} {
+		if _, err = tx.ExecContext(ctx, `DELETE FROM `+table+` WHERE session_id = $1`, results.SessionId.String()); err != nil {
+			return fmt.Errorf("error deleting previous results: %w", err)
+		}
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO session_results (session_id, quiz_id, ended_at) VALUES ($1, $2, $3)`,
+		results.SessionId.String(), int(results.QuizId), results.EndedAt.UnixMilli())
+	if err != nil {
+		return fmt.Errorf("error inserting session results: %w", err)
+	}
+	for _, player := range results.Players {
+		_, err = tx.ExecContext(ctx, `INSERT INTO player_results (session_id, username, rank, score) VALUES ($1, $2, $3, $4)`,
+			results.SessionId.String(), player.Username.String(), player.Rank, float64(player.Score))
+		if err != nil {
+			return fmt.Errorf("error inserting player results: %w", err)
+		}
+	}
+	for _, answer := range results.Answers {
+		selected, err := json.Marshal(answer.Selected)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `INSERT INTO answer_results
+			(session_id, question_index, username, answered_at, response_time_ms, correct, points, selected)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+			results.SessionId.String(), answer.QuestionIndex, answer.Username.String(), answer.AnsweredAt.UnixMilli(),
+			answer.ResponseTimeMs, answer.Correct, float64(answer.Points), string(selected))
+		if err != nil {
+			return fmt.Errorf("error inserting answer results: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *SqlResultsRepository) GetResults(ctx context.Context, sessionId models.SessionId) (*models.SessionResults, error) {
+	results := &models.SessionResults{SessionId: sessionId}
+	var quizId int
+	var endedAt int64
+	err := r.db.QueryRowContext(ctx, `SELECT quiz_id, ended_at FROM session_results WHERE session_id = $1`,
+		sessionId.String()).Scan(&quizId, &endedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, data.ErrResultsNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	results.QuizId = models.QuizId(quizId)
+	results.EndedAt = time.UnixMilli(endedAt)
+	if results.Players, err = r.getPlayers(ctx, sessionId); err != nil {
+		return nil, err
+	}
+	if results.Answers, err = r.getAnswers(ctx, sessionId); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+func (r *SqlResultsRepository) getPlayers(ctx context.Context, sessionId models.SessionId) ([]models.PlayerResult, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT username, rank, score FROM player_results
+		WHERE session_id = $1 ORDER BY rank, username`, sessionId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	players := []models.PlayerResult{}
+	for rows.Next() {
+		var player models.PlayerResult
+		var score float64
+		if err = rows.Scan(&player.Username, &player.Rank, &score); err != nil {
+			return nil, err
+		}
+		player.Score = models.Score(score)
+		players = append(players, player)
+	}
+	return players, rows.Err()
+}
+
+func (r *SqlResultsRepository) getAnswers(ctx context.Context, sessionId models.SessionId) ([]models.AnswerResult, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT question_index, username, answered_at, response_time_ms, correct, points, selected
+		FROM answer_results WHERE session_id = $1 ORDER BY question_index, answered_at`, sessionId.String())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	answers := []models.AnswerResult{}
+	for rows.Next() {
+		var answer models.AnswerResult
+		var answeredAt int64
+		var points float64
+		var selected string
+		err = rows.Scan(&answer.QuestionIndex, &answer.Username, &answeredAt, &answer.ResponseTimeMs, &answer.Correct,
+			&points, &selected)
+		if err != nil {
+			return nil, err
+		}
+		answer.AnsweredAt = time.UnixMilli(answeredAt)
+		answer.Points = models.Score(points)
+		if err = json.Unmarshal([]byte(selected), &answer.Selected); err != nil {
+			return nil, fmt.Errorf("error parsing selected options: %w", err)
+		}
+		answers = append(answers, answer)
+	}
+	return answers, rows.Err()
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"quiz/core/data"
+	"quiz/core/models"
+)
+
+func TestSqlResultsRepository(t *testing.T) {
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// each connection has its own in-memory database
+	db.SetMaxOpenConns(1)
+	repo, err := NewSqlResultsRepository(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	sessionId := models.NewSessionId(1, 2)
+	if _, err = repo.GetResults(ctx, sessionId); !errors.Is(err, data.ErrResultsNotFound) {
+		t.Fatalf("expected ErrResultsNotFound, got %v", err)
+	}
+
+	answeredAt := time.UnixMilli(time.Now().UnixMilli())
+	results := &models.SessionResults{
+		SessionId: sessionId,
+		QuizId:    sessionId.QuizId(),
+		EndedAt:   answeredAt.Add(time.Minute),
+		Players: models.RankPlayers([]models.UserScore{
+			{Username: "alice", Score: 1050.5},
+			{Username: "carol", Score: 800},
+			{Username: "dave", Score: 800},
+			{Username: "bob", Score: 0},
+		}),
+		Answers: []models.AnswerResult{
+			{QuestionIndex: 0, Username: "alice", AnsweredAt: answeredAt, ResponseTimeMs: 1200, Correct: true,
+				Points: 1050.5, Selected: []int{1}},
+			{QuestionIndex: 0, Username: "bob", AnsweredAt: answeredAt.Add(time.Second), ResponseTimeMs: 2200,
+				Selected: []int{0, 2}},
+		},
+	}
+	// saving again replaces the results, as when the activity ending the quiz is retried
+	for range 2 {
+		if err = repo.SaveResults(ctx, results); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved, err := repo.GetResults(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.QuizId != 1 {
+		t.Fatalf("expected quiz 1, got %d", saved.QuizId)
+	}
+	if !saved.EndedAt.Equal(results.EndedAt) {
+		t.Fatalf("expected end %v, got %v", results.EndedAt, saved.EndedAt)
+	}
+	// the tied players share their rank and the next one is skipped
+	expectedPlayers := []models.PlayerResult{
+		{Username: "alice", Rank: 1, Score: 1050.5},
+		{Username: "carol", Rank: 2, Score: 800},
+		{Username: "dave", Rank: 2, Score: 800},
+		{Username: "bob", Rank: 4, Score: 0},
+	}
+	if !reflect.DeepEqual(saved.Players, expectedPlayers) {
+		t.Fatalf("expected players %+v, got %+v", expectedPlayers, saved.Players)
+	}
+	if len(saved.Answers) != len(results.Answers) {
+		t.Fatalf("expected %d answers, got %d", len(results.Answers), len(saved.Answers))
+	}
+	for i, answer := range saved.Answers {
+		expected := results.Answers[i]
+		if !answer.AnsweredAt.Equal(expected.AnsweredAt) {
+			t.Fatalf("answer %d: expected time %v, got %v", i, expected.AnsweredAt, answer.AnsweredAt)
+		}
+		answer.AnsweredAt = expected.AnsweredAt
+		if !reflect.DeepEqual(answer, expected) {
+			t.Fatalf("answer %d: expected %+v, got %+v", i, expected, answer)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"quiz/core/data"
+	"quiz/core/managers"
+	"quiz/core/models"
+	"quiz/workflow"
+)
+
+// registerHostRoutes registers the endpoints used by the host to control a quiz session,
+// authenticated by the host token returned when starting the quiz.
+func (h *webSocketHandler) registerHostRoutes(router *http.ServeMux) {
+	for _, action := range []string{"begin", "pause", "resume", "skip", "extend", "abort"} {
+		router.HandleFunc("OPTIONS /sessions/{sid}/"+action, preflight)
+	}
+	router.HandleFunc("POST /sessions/{sid}/begin", h.hostAction("quiz begun", workflow.BeginQuiz))
+	router.HandleFunc("POST /sessions/{sid}/pause", h.hostAction("quiz paused", workflow.PauseQuizSession))
+	router.HandleFunc("POST /sessions/{sid}/resume", h.hostAction("quiz resumed", workflow.ResumeQuizSession))
+	router.HandleFunc("POST /sessions/{sid}/skip", h.hostAction("question skipped", workflow.SkipQuestion))
+	router.HandleFunc("POST /sessions/{sid}/extend", h.extendQuestion)
+	router.HandleFunc("POST /sessions/{sid}/abort", h.hostAction("quiz aborted", workflow.AbortQuizSession))
+	router.HandleFunc("GET /sessions/{sid}/status", h.getSessionStatus)
+	router.HandleFunc("GET /sessions/{sid}/results", h.getSessionResults)
+}
+
+func (h *webSocketHandler) hostAction(
+	message string, action func(ctx context.Context, sessionId models.SessionId) error,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sessionId, ok := h.authenticateHost(w, r)
+		if !ok {
+			return
+		}
+		if err := action(r.Context(), sessionId); err != nil {
+			writeHostError(w, err)
+			return
+		}
+		writeJson(w, http.StatusOK, map[string]string{"message": message})
+	}
+}
+
+// extendQuestion adds the number of seconds given by the `seconds` query parameter to the current question.
+func (h *webSocketHandler) extendQuestion(w http.ResponseWriter, r *http.Request) {
+	sessionId, ok := h.authenticateHost(w, r)
+	if !ok {
+		return
+	}
+	seconds, err := strconv.Atoi(r.URL.Query().Get("seconds"))
+	if err != nil || seconds <= 0 {
+		http.Error(w, jsonError("seconds must be a positive integer"), http.StatusBadRequest)
+		return
+	}
+	if err = workflow.AddQuestionTime(r.Context(), sessionId, seconds); err != nil {
+		writeHostError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, map[string]string{"message": "question extended"})
+}
+
+// authenticateHost checks the `Authorization: Bearer <host token>` header of the request.
+func (h *webSocketHandler) authenticateHost(w http.ResponseWriter, r *http.Request) (models.SessionId, bool) {
+	sessionId := models.SessionId(r.PathValue("sid"))
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		writeHostError(w, managers.ErrInvalidHostToken)
+		return "", false
+	}
+	if err := managers.CheckHostToken(r.Context(), sessionId, token); err != nil {
+		writeHostError(w, err)
+		return "", false
+	}
+	return sessionId, true
+}
+
+// getSessionStatus reports the state of the quiz session.
+func (h *webSocketHandler) getSessionStatus(w http.ResponseWriter, r *http.Request) {
+	h.writeSessionStatus(w, r, models.SessionId(r.PathValue("sid")))
+}
+
+// writeSessionStatus writes the status of the session, completed with its participant count while it is running.
+func (h *webSocketHandler) writeSessionStatus(w http.ResponseWriter, r *http.Request, sessionId models.SessionId) {
+	status, err := workflow.GetSessionStatus(r.Context(), sessionId)
+	if err != nil {
+		writeHostError(w, err)
+		return
+	}
+	if status.Phase != models.EndedPhase {
+		if status.ParticipantCount, err = managers.CountParticipants(r.Context(), sessionId); err != nil {
+			writeHostError(w, err)
+			return
+		}
+	}
+	writeJson(w, http.StatusOK, status)
+}
+
+// getSessionResults returns the final scores and answers of an ended quiz session.
+func (h *webSocketHandler) getSessionResults(w http.ResponseWriter, r *http.Request) {
+	results, err := h.quizResultsManager.GetResults(r.Context(), models.SessionId(r.PathValue("sid")))
+	if err != nil {
+		writeHostError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, results)
+}
+
+// writeHostError maps the quiz session errors to the HTTP status codes.
+func writeHostError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, managers.ErrInvalidHostToken):
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		http.Error(w, jsonError(err.Error()), http.StatusUnauthorized)
+	case errors.Is(err, workflow.ErrSessionNotFound), errors.Is(err, data.ErrResultsNotFound):
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		http.Error(w, jsonError(err.Error()), http.StatusNotFound)
+	default:
+		writeQuizError(w, err)
+	}
+}
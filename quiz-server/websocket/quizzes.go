@@ -0,0 +1,294 @@
+package websocket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"quiz/core/data"
+	"quiz/core/managers"
+	"quiz/core/models"
+	"quiz/core/questions"
+	"quiz/workflow"
+)
+
+// registerQuizRoutes registers the quiz authoring API.
+// The quizzes are edited by the authors, authenticated by the admin token. Anyone can read them,
+// but only the authors get the correct answers, which the players would otherwise fetch during a quiz.
+func (h *webSocketHandler) registerQuizRoutes(router *http.ServeMux) {
+	router.HandleFunc("OPTIONS /quizzes", preflight)
+	router.HandleFunc("OPTIONS /quizzes/", preflight)
+	router.HandleFunc("GET /quizzes", h.listQuizzes)
+	router.HandleFunc("POST /quizzes", authorOnly(h.createQuiz))
+	router.HandleFunc("GET /quizzes/{id}", h.getQuiz)
+	router.HandleFunc("PUT /quizzes/{id}", authorOnly(h.updateQuiz))
+	router.HandleFunc("DELETE /quizzes/{id}", authorOnly(h.deleteQuiz))
+	router.HandleFunc("GET /quizzes/{id}/status", h.getQuizStatus)
+	router.HandleFunc("GET /quizzes/{id}/questions", h.listQuestions)
+	router.HandleFunc("POST /quizzes/{id}/questions", authorOnly(h.addQuestion))
+	router.HandleFunc("GET /quizzes/{id}/questions/{index}", h.getQuestion)
+	router.HandleFunc("PUT /quizzes/{id}/questions/{index}", authorOnly(h.updateQuestion))
+	router.HandleFunc("DELETE /quizzes/{id}/questions/{index}", authorOnly(h.deleteQuestion))
+}
+
+// authorOnly rejects the requests without the `Authorization: Bearer <admin token>` header.
+func authorOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isAuthor(r) {
+			writeQuizError(w, managers.ErrInvalidAdminToken)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func isAuthor(r *http.Request) bool {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return found && managers.CheckAdminToken(token) == nil
+}
+
+func (h *webSocketHandler) listQuizzes(w http.ResponseWriter, r *http.Request) {
+	quizzes, err := h.quizContentManager.ListQuizzes(r.Context())
+	if err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	if !isAuthor(r) {
+		for i, quiz := range quizzes {
+			quizzes[i] = questions.RedactQuiz(quiz)
+		}
+	}
+	writeJson(w, http.StatusOK, quizzes)
+}
+
+func (h *webSocketHandler) createQuiz(w http.ResponseWriter, r *http.Request) {
+	quiz := &models.Quiz{}
+	if err := decodeJson(r, quiz); err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+		return
+	}
+	created, err := h.quizContentManager.CreateQuiz(r.Context(), quiz)
+	if err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	writeJson(w, http.StatusCreated, created)
+}
+
+func (h *webSocketHandler) getQuiz(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	quiz, err := h.quizContentManager.GetQuiz(r.Context(), quizId)
+	if err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	if !isAuthor(r) {
+		quiz = questions.RedactQuiz(quiz)
+	}
+	writeJson(w, http.StatusOK, quiz)
+}
+
+func (h *webSocketHandler) updateQuiz(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	quiz := &models.Quiz{}
+	if err := decodeJson(r, quiz); err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+		return
+	}
+	quiz.Id = quizId
+	updated, err := h.quizContentManager.UpdateQuiz(r.Context(), quiz)
+	if err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, updated)
+}
+
+func (h *webSocketHandler) deleteQuiz(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	if err := h.quizContentManager.DeleteQuiz(r.Context(), quizId); err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getQuizStatus reports the state of the last session started for the quiz.
+func (h *webSocketHandler) getQuizStatus(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	sessionId, err := workflow.LastSessionId(r.Context(), quizId)
+	if err != nil {
+		writeHostError(w, err)
+		return
+	}
+	h.writeSessionStatus(w, r, sessionId)
+}
+
+func (h *webSocketHandler) listQuestions(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	quiz, err := h.quizContentManager.GetQuiz(r.Context(), quizId)
+	if err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	if !isAuthor(r) {
+		quiz = questions.RedactQuiz(quiz)
+	}
+	writeJson(w, http.StatusOK, quiz.Questions)
+}
+
+func (h *webSocketHandler) addQuestion(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	question := &models.Question{}
+	if err := decodeJson(r, question); err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+		return
+	}
+	index, err := h.quizContentManager.AddQuestion(r.Context(), quizId, question)
+	if err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/quizzes/%d/questions/%d", quizId, index))
+	writeJson(w, http.StatusCreated, question)
+}
+
+func (h *webSocketHandler) getQuestion(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	questionIndex, ok := parseQuestionIndex(w, r)
+	if !ok {
+		return
+	}
+	question, err := h.quizContentManager.GetQuestion(r.Context(), quizId, questionIndex)
+	if err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	if !isAuthor(r) {
+		redacted := questions.RedactQuestion(question)
+		question = &redacted
+	}
+	writeJson(w, http.StatusOK, question)
+}
+
+func (h *webSocketHandler) updateQuestion(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	questionIndex, ok := parseQuestionIndex(w, r)
+	if !ok {
+		return
+	}
+	question := &models.Question{}
+	if err := decodeJson(r, question); err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+		return
+	}
+	if err := h.quizContentManager.UpdateQuestion(r.Context(), quizId, questionIndex, question); err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, question)
+}
+
+func (h *webSocketHandler) deleteQuestion(w http.ResponseWriter, r *http.Request) {
+	quizId, ok := parseQuizId(w, r)
+	if !ok {
+		return
+	}
+	questionIndex, ok := parseQuestionIndex(w, r)
+	if !ok {
+		return
+	}
+	if err := h.quizContentManager.DeleteQuestion(r.Context(), quizId, questionIndex); err != nil {
+		writeQuizError(w, err)
+		return
+	}
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func parseQuizId(w http.ResponseWriter, r *http.Request) (models.QuizId, bool) {
+	quizId, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, jsonError("invalid quiz id"), http.StatusBadRequest)
+		return 0, false
+	}
+	return models.QuizId(quizId), true
+}
+
+func parseQuestionIndex(w http.ResponseWriter, r *http.Request) (int, bool) {
+	questionIndex, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil {
+		http.Error(w, jsonError("invalid question index"), http.StatusBadRequest)
+		return 0, false
+	}
+	return questionIndex, true
+}
+
+func decodeJson(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid request body: %w", err)
+	}
+	return nil
+}
+
+// writeQuizError maps the quiz content errors to the HTTP status codes.
+func writeQuizError(w http.ResponseWriter, err error) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	switch {
+	case errors.Is(err, managers.ErrInvalidAdminToken):
+		http.Error(w, jsonError(err.Error()), http.StatusUnauthorized)
+	case errors.Is(err, models.ErrInvalidQuiz):
+		http.Error(w, jsonError(err.Error()), http.StatusBadRequest)
+	case errors.Is(err, data.ErrQuizNotFound), errors.Is(err, managers.ErrQuestionNotFound):
+		http.Error(w, jsonError(err.Error()), http.StatusNotFound)
+	case errors.Is(err, managers.QuizInProgressError), errors.Is(err, managers.ErrQuizBeingEdited):
+		http.Error(w, jsonError(err.Error()), http.StatusConflict)
+	default:
+		http.Error(w, jsonError(err.Error()), http.StatusInternalServerError)
+	}
+}
+
+func writeJson(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// preflight handles the CORS preflight requests of the API.
+func preflight(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+	w.WriteHeader(http.StatusOK)
+}
@@ -1,6 +1,8 @@
 package socket
 
 import (
+	"time"
+
 	socketio "github.com/karagenc/socket.io-go"
 	"quiz/configs"
 	"quiz/core/models"
@@ -13,14 +15,75 @@ func StartServer() *socketio.Server {
 	return server
 }
 
-func NotifyQuestionEnded(quizId models.QuizId, currentQuestionIndex int, leaderboard []models.UserScore) {
-	server.Of("").In(socketio.Room(quizId.String())).Emit(string(configs.QuestionStarted), currentQuestionIndex, leaderboard)
+// emitToSession emits the event to the players of the session, with the server time as unix milliseconds
+// as last argument, so that the clients synchronised with time_sync can tell how long ago it was sent.
+func emitToSession(sessionId models.SessionId, event configs.SocketEvent, args ...any) {
+	args = append(args, time.Now().UnixMilli())
+	server.Of("").In(socketio.Room(sessionId.String())).Emit(string(event), args...)
+}
+
+// Emit emits the event to a single socket, with the server time as last argument like the session events.
+func Emit(s socketio.ServerSocket, event configs.SocketEvent, args ...any) {
+	args = append(args, time.Now().UnixMilli())
+	s.Emit(string(event), args...)
+}
+
+// NotifyQuestionStarted notifies the start of the question, with its answer window as unix milliseconds.
+func NotifyQuestionStarted(sessionId models.SessionId, currentQuestionIndex int, leaderboard []models.UserScore, startedAt, deadline time.Time) {
+	emitToSession(sessionId, configs.QuestionStarted, currentQuestionIndex, leaderboard,
+		startedAt.UnixMilli(), deadline.UnixMilli())
+}
+
+// NotifyQuestionClosed notifies that the time to answer the question is up.
+func NotifyQuestionClosed(sessionId models.SessionId, currentQuestionIndex int) {
+	emitToSession(sessionId, configs.QuestionClosed, currentQuestionIndex)
+}
+
+// NotifyQuestionEnded reveals the correct answer of the question and the answer distribution.
+func NotifyQuestionEnded(sessionId models.SessionId, results *models.QuestionResults) {
+	emitToSession(sessionId, configs.QuestionEnded, results)
+}
+
+func NotifyQuizEnded(sessionId models.SessionId, leaderboard []models.UserScore) {
+	emitToSession(sessionId, configs.QuizEnded, leaderboard)
+}
+
+func NotifyScoreUpdated(sessionId models.SessionId, username models.Username, leaderboard []models.UserScore) {
+	emitToSession(sessionId, configs.ScoreUpdated, username, leaderboard)
+}
+
+// NotifyQuizPaused notifies that the host paused the quiz, with the answer time left in milliseconds.
+func NotifyQuizPaused(sessionId models.SessionId, currentQuestionIndex int, remaining time.Duration) {
+	emitToSession(sessionId, configs.QuizPaused, currentQuestionIndex,
+		remaining.Milliseconds())
+}
+
+// NotifyQuizResumed notifies that the host resumed the quiz, with the shifted answer window as unix milliseconds.
+func NotifyQuizResumed(sessionId models.SessionId, currentQuestionIndex int, startedAt, deadline time.Time) {
+	emitToSession(sessionId, configs.QuizResumed, currentQuestionIndex,
+		startedAt.UnixMilli(), deadline.UnixMilli())
+}
+
+// NotifyQuestionExtended notifies that the host added time to the question, with its new deadline as unix milliseconds
+// and the answer time left in milliseconds, which is the one to display if the quiz is paused.
+func NotifyQuestionExtended(sessionId models.SessionId, currentQuestionIndex int, deadline time.Time, remaining time.Duration) {
+	emitToSession(sessionId, configs.QuestionExtended, currentQuestionIndex,
+		deadline.UnixMilli(), remaining.Milliseconds())
+}
+
+func NotifyQuizAborted(sessionId models.SessionId, reason string, leaderboard []models.UserScore) {
+	emitToSession(sessionId, configs.QuizAborted, reason, leaderboard)
+}
+
+func NotifyPlayerJoined(sessionId models.SessionId, username models.Username, participants []models.Username) {
+	emitToSession(sessionId, configs.PlayerJoined, username, participants)
 }
 
-func NotifyQuizEnded(quizId models.QuizId, leaderboard []models.UserScore) {
-	server.Of("").In(socketio.Room(quizId.String())).Emit(string(configs.QuizEnded), leaderboard)
+func NotifyPlayerLeft(sessionId models.SessionId, username models.Username, participants []models.Username) {
+	emitToSession(sessionId, configs.PlayerLeft, username, participants)
 }
 
-func NotifyScoreUpdated(quizId models.QuizId, username models.Username, leaderboard []models.UserScore) {
-	server.Of("").In(socketio.Room(quizId.String())).Emit(string(configs.ScoreUpdated), username, leaderboard)
+// NotifyMyRank sends the player of the socket its own rank, which may be outside the leaderboard.
+func NotifyMyRank(s socketio.ServerSocket, rank *models.PlayerRank) {
+	Emit(s, configs.MyRank, rank)
 }
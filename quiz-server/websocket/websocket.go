@@ -15,6 +15,7 @@ import (
 	"quiz/core/data"
 	"quiz/core/managers"
 	"quiz/core/models"
+	"quiz/websocket/socket"
 	"quiz/workflow"
 
 	socketio "github.com/karagenc/socket.io-go"
@@ -22,6 +23,8 @@ import (
 
 type webSocketHandler struct {
 	quizSessionManager *managers.QuizSession
+	quizContentManager *managers.QuizContent
+	quizResultsManager *managers.QuizResults
 	server             *socketio.Server
 }
 
@@ -37,7 +40,10 @@ func corsMiddleware(next http.Handler) http.Handler {
 	})
 }
 
-func ListenAndHandleEvent(manager *managers.QuizSession, server *socketio.Server) {
+func ListenAndHandleEvent(
+	manager *managers.QuizSession, contentManager *managers.QuizContent, resultsManager *managers.QuizResults,
+	server *socketio.Server,
+) {
 	portStr := os.Getenv("PORT")
 	_, err := strconv.Atoi(portStr)
 	if err != nil {
@@ -46,15 +52,20 @@ func ListenAndHandleEvent(manager *managers.QuizSession, server *socketio.Server
 
 	handler := &webSocketHandler{
 		quizSessionManager: manager,
+		quizContentManager: contentManager,
+		quizResultsManager: resultsManager,
 		server:             server,
 	}
 	server.Of("/").OnConnection(func(socket socketio.ServerSocket) {
 		fmt.Println("on connect:", socket.ID())
 		socket.OnEvent(string(configs.AnswerQuestion), handler.onQuestionAnswered(socket))
 		socket.OnEvent(string(configs.JoinQuiz), handler.onJoinQuiz(socket))
+		socket.OnEvent(string(configs.ResumeSession), handler.onResumeSession(socket))
+		socket.OnEvent(string(configs.TimeSync), onTimeSync(socket))
 
 		socket.OnDisconnect(func(reason socketio.Reason) {
 			fmt.Println("on disconnect:", reason)
+			handler.quizSessionManager.LeaveQuiz(context.Background(), socket)
 		})
 	})
 	if err := server.Run(); err != nil {
@@ -66,7 +77,9 @@ func ListenAndHandleEvent(manager *managers.QuizSession, server *socketio.Server
 	router.Handle("/socket.io/", corsMiddleware(server))
 	router.Handle("/", fs)
 	// Define a simple GET route
-	router.HandleFunc("/start/", startQuiz)
+	router.HandleFunc("/start/", handler.startQuiz)
+	handler.registerHostRoutes(router)
+	handler.registerQuizRoutes(router)
 
 	httpServer := &http.Server{
 		Addr:    "0.0.0.0:" + portStr,
@@ -88,7 +101,7 @@ func ListenAndHandleEvent(manager *managers.QuizSession, server *socketio.Server
 	}
 }
 
-func startQuiz(w http.ResponseWriter, r *http.Request) {
+func (h *webSocketHandler) startQuiz(w http.ResponseWriter, r *http.Request) {
 	// Add CORS headers
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
@@ -109,23 +122,53 @@ func startQuiz(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch quiz data
-	quiz := data.QuizData[models.QuizId(quizId)]
-	if quiz == nil {
+	quiz, err := h.quizContentManager.GetQuiz(r.Context(), models.QuizId(quizId))
+	if errors.Is(err, data.ErrQuizNotFound) {
 		http.Error(w, jsonError("quiz not found"), http.StatusNotFound)
 		return
 	}
+	if err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusInternalServerError)
+		return
+	}
 
-	// Start the quiz workflow
-	if err = workflow.StartQuizWorkflow(r.Context(), quiz); err != nil {
+	// The host token and the PIN are created before the workflow starts,
+	// so that a running session always has a host and can be joined
+	sessionId, err := managers.NewQuizSession(r.Context(), quiz.Id)
+	if err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	// The host token authenticates the host controls of the session
+	hostToken, err := managers.CreateHostToken(r.Context(), sessionId, quiz.LockDuration())
+	if err != nil {
+		http.Error(w, jsonError(err.Error()), http.StatusInternalServerError)
+		return
+	}
+	// The participants join the session with its PIN
+	pin, err := managers.CreateSessionPin(r.Context(), sessionId, quiz.Id, quiz.LockDuration())
+	if err != nil {
+		managers.DiscardQuizSession(r.Context(), sessionId)
 		http.Error(w, jsonError(err.Error()), http.StatusInternalServerError)
 		return
 	}
 
+	// Start the quiz workflow
+	if err = workflow.StartQuizWorkflow(r.Context(), sessionId, quiz); err != nil {
+		managers.DiscardQuizSession(r.Context(), sessionId)
+		writeQuizError(w, err)
+		return
+	}
+
 	// Send success response
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "start quiz successfully",
+		"message":     "start quiz successfully",
+		"session_id":  sessionId.String(),
+		"workflow_id": sessionId.String(),
+		"host_token":  hostToken,
+		"pin":         pin,
 	})
 }
 
@@ -138,44 +181,121 @@ func jsonError(message string) string {
 
 var JoinQuizError = errors.New("JoinQuizError")
 
-func (h *webSocketHandler) onJoinQuiz(s socketio.ServerSocket) func(username string, quizId int) {
-	return func(username string, quizId int) {
+func (h *webSocketHandler) onJoinQuiz(s socketio.ServerSocket) func(username string, pin string) {
+	return func(username string, pin string) {
 		if username == "" {
-			s.Emit(string(configs.Error), fmt.Sprintf("%s: user id is empty", JoinQuizError))
+			socket.Emit(s, configs.Error, fmt.Sprintf("%s: user id is empty", JoinQuizError))
 			return
 		}
 
-		quiz, err := h.quizSessionManager.JoinQuiz(context.Background(), models.QuizId(quizId), models.Username(username), s)
+		ctx := context.Background()
+		session, err := managers.ResolvePin(ctx, pin)
 		if err != nil {
-			s.Emit(string(configs.Error), fmt.Sprintf("%s: %s", JoinQuizError, err))
+			socket.Emit(s, configs.Error, fmt.Sprintf("%s: %s", JoinQuizError, err))
 			fmt.Println(fmt.Sprintf("join quiz err: %s", err))
 			return
 		}
-		s.Emit(string(configs.QuizData), quiz)
-		fmt.Println("join quiz successfully. username:", username, "quizid:", quizId)
-		h.server.SocketsJoin(socketio.Room(models.QuizId(quizId).String()))
+		sessionId := session.SessionId
+
+		// join the room first to receive the player_joined event of this user
+		room := socketio.Room(sessionId.String())
+		s.Join(room)
+		quiz, err := h.quizSessionManager.JoinQuiz(ctx, sessionId, session.QuizId, models.Username(username), s)
+		if err != nil {
+			s.Leave(room)
+			socket.Emit(s, configs.Error, fmt.Sprintf("%s: %s", JoinQuizError, err))
+			fmt.Println(fmt.Sprintf("join quiz err: %s", err))
+			return
+		}
+		// the reconnect token lets the user resume the session after a disconnection
+		reconnectToken, err := managers.CreateReconnectToken(sessionId, session.QuizId, models.Username(username), quiz.LockDuration())
+		if err != nil {
+			fmt.Println("create reconnect token err:", err)
+		}
+		socket.Emit(s, configs.QuizData, quiz, sessionId, reconnectToken)
+		fmt.Println("join quiz successfully. username:", username, "quizid:", session.QuizId, "session:", sessionId)
+		participants, err := h.quizSessionManager.GetParticipants(ctx, sessionId)
+		if err != nil {
+			fmt.Println("get participants err:", err)
+			return
+		}
+		socket.Emit(s, configs.LobbyState, participants)
+		if quiz.StartMode == models.HostStart && quiz.AutoStartPlayers > 0 && len(participants) >= quiz.AutoStartPlayers {
+			if err = workflow.BeginQuiz(ctx, sessionId); err != nil {
+				fmt.Println("auto start quiz err:", err)
+			}
+		}
 
 		return
 	}
 }
 
+var ResumeSessionError = errors.New("ResumeSessionError")
+
+// onResumeSession binds the socket to the quiz session of the reconnect token,
+// and sends the quiz, the participants and the state of the session to the user.
+func (h *webSocketHandler) onResumeSession(s socketio.ServerSocket) func(token string) {
+	return func(token string) {
+		claims, err := managers.ParseReconnectToken(token)
+		if err != nil {
+			socket.Emit(s, configs.Error, fmt.Sprintf("%s: %s", ResumeSessionError, err))
+			return
+		}
+
+		ctx := context.Background()
+		room := socketio.Room(claims.SessionId.String())
+		s.Join(room)
+		quiz, state, err := h.quizSessionManager.ResumeSession(ctx, claims, s)
+		if err != nil {
+			s.Leave(room)
+			socket.Emit(s, configs.Error, fmt.Sprintf("%s: %s", ResumeSessionError, err))
+			fmt.Println(fmt.Sprintf("resume session err: %s", err))
+			return
+		}
+		socket.Emit(s, configs.QuizData, quiz, claims.SessionId, token)
+		socket.Emit(s, configs.SessionResumed, state)
+		fmt.Println("resume session successfully. username:", claims.Username, "session:", claims.SessionId)
+		participants, err := h.quizSessionManager.GetParticipants(ctx, claims.SessionId)
+		if err != nil {
+			fmt.Println("get participants err:", err)
+			return
+		}
+		socket.Emit(s, configs.LobbyState, participants)
+	}
+}
+
+// onTimeSync answers an NTP-style clock synchronisation request: the client sends its clock t0 and receives
+// t0 with the server clock at the reception t1 and at the reply t2, all as unix milliseconds.
+// With t3 the client clock at the reply, the round trip is (t3 - t0) - (t2 - t1)
+// and the server clock is ahead of the client clock by ((t1 - t0) + (t2 - t3)) / 2.
+func onTimeSync(s socketio.ServerSocket) func(clientSentAt int64, ack func(clientSentAt, serverReceivedAt, serverSentAt int64)) {
+	return func(clientSentAt int64, ack func(clientSentAt, serverReceivedAt, serverSentAt int64)) {
+		serverReceivedAt := time.Now().UnixMilli()
+		if ack == nil {
+			s.Emit(string(configs.TimeSync), clientSentAt, serverReceivedAt, time.Now().UnixMilli())
+			return
+		}
+		ack(clientSentAt, serverReceivedAt, time.Now().UnixMilli())
+	}
+}
+
 func (h *webSocketHandler) onQuestionAnswered(s socketio.ServerSocket) func(msg string) {
 	return func(msg string) {
 		fmt.Println("on question answered", msg)
 		answer := &models.QuestionAnsweredPayload{}
 		err := json.Unmarshal([]byte(msg), answer)
 		if err != nil {
-			s.Emit(string(configs.Error), "invalid data")
+			socket.Emit(s, configs.Error, "invalid data")
 			return
 		}
 
-		res, err := h.quizSessionManager.AnswerQuestion(s, answer.QuizId, answer.QuestionIndex, answer.AnswerIndex)
+		res, err := h.quizSessionManager.AnswerQuestion(s, answer)
 		if err != nil {
-			s.Emit(string(configs.Error), err.Error())
+			socket.Emit(s, configs.Error, err.Error())
 			fmt.Println("handle question answered websocket event error:", err)
 			return
 		}
-		s.Emit(string(configs.AnswerChecked), res.CorrectAnswerIndex, res.NewScore)
+		socket.Emit(s, configs.AnswerChecked, res.CorrectAnswerIndex, res.NewScore, res)
 		return
 	}
 }
@@ -6,6 +6,9 @@ import (
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
 	"quiz/configs"
+	"quiz/core/managers"
+	"quiz/event_publisher"
+	"quiz/repository"
 	"quiz/workflow"
 )
 
@@ -18,13 +21,29 @@ func main() {
 		log.Fatalln("unable to create Temporal client", err)
 	}
 	defer c.Close()
+	if err := event_publisher.Connect(); err != nil {
+		log.Fatalln("Failed to start Sarama producer:", err)
+	}
+
+	resultsRepository, err := repository.NewResultsRepository()
+	if err != nil {
+		log.Fatalln("unable to create results repository:", err)
+	}
+	workflow.SetResultsManager(managers.NewQuizResultsManager(resultsRepository))
 
 	// This worker hosts both Workflow and Activity functions
 	w := worker.New(c, workflow.QuizTaskQueue, worker.Options{})
 	w.RegisterWorkflow(workflow.QuizSessionWorkflow)
 	w.RegisterActivity(workflow.StartQuiz)
 	w.RegisterActivity(workflow.StartNewQuestion)
+	w.RegisterActivity(workflow.CloseQuestion)
+	w.RegisterActivity(workflow.EndQuestion)
 	w.RegisterActivity(workflow.EndQuiz)
+	w.RegisterActivity(workflow.AbortQuiz)
+	w.RegisterActivity(workflow.PauseQuiz)
+	w.RegisterActivity(workflow.ResumeQuiz)
+	w.RegisterActivity(workflow.CancelPause)
+	w.RegisterActivity(workflow.ExtendQuestion)
 
 	// Start listening to the Task Queue
 	err = w.Run(worker.InterruptCh())
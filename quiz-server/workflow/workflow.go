@@ -2,10 +2,12 @@ package workflow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"go.temporal.io/api/serviceerror"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
@@ -28,15 +30,45 @@ func StartWorkflowClient() client.Client {
 	return c
 }
 
+// resultsManager saves the results of the sessions when they end, set by the worker hosting the activities.
+var resultsManager *managers.QuizResults
+
+func SetResultsManager(manager *managers.QuizResults) {
+	resultsManager = manager
+}
+
 const QuizTaskQueue = "QUIZ_TASK_QUEUE"
 
-func StartQuizWorkflow(ctx context.Context, quiz *models.Quiz) error {
+// Signals sent by the host to control the quiz session.
+const (
+	PauseSignal   = "pause"
+	ResumeSignal  = "resume"
+	SkipSignal    = "skip"
+	AddTimeSignal = "add_time" // with the number of seconds to add to the current question
+	BeginSignal   = "begin"    // ends the lobby, ignored once the questions started
+)
+
+// StatusQuery returns the models.QuizSessionStatus of the session.
+const StatusQuery = "status"
+
+var ErrSessionNotFound = errors.New("quiz session not found")
+
+// StartQuizWorkflow starts the session of the quiz reserved by managers.NewQuizSession.
+// The session ID is also the workflow ID.
+func StartQuizWorkflow(ctx context.Context, sessionId models.SessionId, quiz *models.Quiz) error {
 	options := client.StartWorkflowOptions{
+		ID:        sessionId.String(),
 		TaskQueue: QuizTaskQueue,
+		// fail instead of returning an existing session, eg if the run counter was reset
+		WorkflowExecutionErrorWhenAlreadyStarted: true,
 	}
 
-	we, err := c.ExecuteWorkflow(ctx, options, QuizSessionWorkflow, quiz)
+	we, err := c.ExecuteWorkflow(ctx, options, QuizSessionWorkflow, sessionId, quiz)
 	if err != nil {
+		var alreadyStarted *serviceerror.WorkflowExecutionAlreadyStarted
+		if errors.As(err, &alreadyStarted) {
+			return managers.QuizInProgressError
+		}
 		return err
 	}
 
@@ -45,15 +77,110 @@ func StartQuizWorkflow(ctx context.Context, quiz *models.Quiz) error {
 	return nil
 }
 
+// LastSessionId returns the ID of the last session started for the quiz.
+func LastSessionId(ctx context.Context, quizId models.QuizId) (models.SessionId, error) {
+	run, err := managers.GetLastQuizRun(ctx, quizId)
+	if err != nil {
+		return "", err
+	}
+	if run == 0 {
+		return "", ErrSessionNotFound
+	}
+	return models.NewSessionId(quizId, run), nil
+}
+
+// GetSessionStatus queries the workflow of the quiz session.
+func GetSessionStatus(ctx context.Context, sessionId models.SessionId) (*models.QuizSessionStatus, error) {
+	res, err := c.QueryWorkflow(ctx, sessionId.String(), "", StatusQuery)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	status := &models.QuizSessionStatus{}
+	if err = res.Get(status); err != nil {
+		return nil, err
+	}
+	status.SessionId = sessionId
+	if status.Phase != models.EndedPhase && !status.Paused {
+		status.TimeRemainingMs = max(0, time.Until(status.Deadline).Milliseconds())
+	}
+	return status, nil
+}
+
+func PauseQuizSession(ctx context.Context, sessionId models.SessionId) error {
+	return signalQuizSession(ctx, sessionId, PauseSignal, nil)
+}
+
+func ResumeQuizSession(ctx context.Context, sessionId models.SessionId) error {
+	return signalQuizSession(ctx, sessionId, ResumeSignal, nil)
+}
+
+func SkipQuestion(ctx context.Context, sessionId models.SessionId) error {
+	return signalQuizSession(ctx, sessionId, SkipSignal, nil)
+}
+
+// AbortQuizSession cancels the session workflow, which releases the runtime state of the session.
+func AbortQuizSession(ctx context.Context, sessionId models.SessionId) error {
+	err := c.CancelWorkflow(ctx, sessionId.String(), "")
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+func BeginQuiz(ctx context.Context, sessionId models.SessionId) error {
+	return signalQuizSession(ctx, sessionId, BeginSignal, nil)
+}
+
+func AddQuestionTime(ctx context.Context, sessionId models.SessionId, seconds int) error {
+	return signalQuizSession(ctx, sessionId, AddTimeSignal, seconds)
+}
+
+func signalQuizSession(ctx context.Context, sessionId models.SessionId, signal string, arg any) error {
+	err := c.SignalWorkflow(ctx, sessionId.String(), "", signal, arg)
+	var notFound *serviceerror.NotFound
+	if errors.As(err, &notFound) {
+		return ErrSessionNotFound
+	}
+	return err
+}
+
+type startQuizPayload struct {
+	SessionId    models.SessionId
+	QuizId       models.QuizId
+	LockDuration time.Duration
+}
+
 type newQuestionPayload struct {
-	QuizId               models.QuizId
+	SessionId            models.SessionId
 	CurrentQuestionIndex int
+	StartedAt            time.Time
+	Deadline             time.Time
+	PausedFor            time.Duration       // when resuming the quiz
+	Phase                models.SessionPhase // when resuming the quiz
 }
 
-func QuizSessionWorkflow(ctx workflow.Context, quiz *models.Quiz) error {
+type questionPayload struct {
+	SessionId            models.SessionId
+	CurrentQuestionIndex int
+	Question             *models.Question
+}
+
+type hostActionPayload struct {
+	SessionId            models.SessionId
+	CurrentQuestionIndex int
+	Deadline             time.Time
+	Remaining            time.Duration
+	Added                time.Duration
+}
+
+func QuizSessionWorkflow(ctx workflow.Context, sessionId models.SessionId, quiz *models.Quiz) error {
 	options := workflow.ActivityOptions{
-		StartToCloseTimeout: configs.DefaultQuestionTime + // pending period
-			time.Duration(len(quiz.Questions))*configs.DefaultQuestionTime + // question period
+		StartToCloseTimeout: quiz.Duration() + // lobby & question periods
 			time.Minute, // timeout period
 		RetryPolicy: &temporal.RetryPolicy{
 			InitialInterval:        5 * time.Second,
@@ -64,35 +191,275 @@ func QuizSessionWorkflow(ctx workflow.Context, quiz *models.Quiz) error {
 	}
 	ctx = workflow.WithActivityOptions(ctx, options)
 
-	if err := workflow.ExecuteActivity(ctx, StartQuiz, quiz.Id).Get(ctx, nil); err != nil {
+	status := &models.QuizSessionStatus{Phase: models.LobbyPhase, QuestionIndex: -1}
+	if err := workflow.SetQueryHandler(ctx, StatusQuery, func() (*models.QuizSessionStatus, error) {
+		return status, nil
+	}); err != nil {
+		return err
+	}
+	defer func() {
+		if !errors.Is(ctx.Err(), workflow.ErrCanceled) {
+			return
+		}
+		// the workflow context is cancelled, the compensation runs in a disconnected context
+		abortCtx, _ := workflow.NewDisconnectedContext(ctx)
+		if err := workflow.ExecuteActivity(abortCtx, AbortQuiz, sessionId).Get(abortCtx, nil); err != nil {
+			workflow.GetLogger(ctx).Error("error aborting quiz", "error", err)
+		}
+		status.Phase, status.Deadline, status.TimeRemainingMs = models.AbortedPhase, time.Time{}, 0
+	}()
+
+	startPayload := &startQuizPayload{
+		SessionId:    sessionId,
+		QuizId:       quiz.Id,
+		LockDuration: quiz.LockDuration(),
+	}
+	if err := workflow.ExecuteActivity(ctx, StartQuiz, startPayload).Get(ctx, nil); err != nil {
+		return err
+	}
+	controls := newHostControls(ctx, status)
+	lobbyStartedAt := workflow.Now(ctx)
+	lobby := &newQuestionPayload{
+		SessionId:            sessionId,
+		CurrentQuestionIndex: -1,
+		StartedAt:            lobbyStartedAt,
+		Deadline:             lobbyStartedAt.Add(quiz.LobbyDuration()),
+	}
+	if err := controls.waitForDeadline(ctx, models.LobbyPhase, lobby); err != nil {
 		return err
 	}
-	workflow.Sleep(ctx, configs.DefaultQuestionTime)
 
 	for i := range quiz.Questions {
+		startedAt := workflow.Now(ctx)
 		payload := &newQuestionPayload{
-			QuizId:               quiz.Id,
+			SessionId:            sessionId,
 			CurrentQuestionIndex: i,
+			StartedAt:            startedAt,
+			Deadline:             startedAt.Add(quiz.QuestionDuration(i)),
 		}
 		if err := workflow.ExecuteActivity(ctx, StartNewQuestion, payload).Get(ctx, nil); err != nil {
 			return err
 		}
-		workflow.Sleep(ctx, configs.DefaultQuestionTime)
+		// wait until the deadline sent to the participants, which includes the activity latency
+		if err := controls.waitForDeadline(ctx, models.QuestionPhase, payload); err != nil {
+			return err
+		}
+		// the question is closed to the answers before its results are revealed
+		results := &questionPayload{
+			SessionId:            sessionId,
+			CurrentQuestionIndex: i,
+			Question:             &quiz.Questions[i],
+		}
+		// the late answers are accepted during the grace period, unless the host skipped the question
+		if !workflow.Now(ctx).Before(payload.Deadline) {
+			if err := workflow.Sleep(ctx, configs.AnswerGracePeriod); err != nil {
+				return err
+			}
+		}
+		status.Phase, status.Deadline, status.TimeRemainingMs = models.ClosedPhase, time.Time{}, 0
+		if err := workflow.ExecuteActivity(ctx, CloseQuestion, results).Get(ctx, nil); err != nil {
+			return err
+		}
+		if err := workflow.ExecuteActivity(ctx, EndQuestion, results).Get(ctx, nil); err != nil {
+			return err
+		}
+		revealStartedAt := workflow.Now(ctx)
+		reveal := &newQuestionPayload{
+			SessionId:            sessionId,
+			CurrentQuestionIndex: i,
+			StartedAt:            revealStartedAt,
+			Deadline:             revealStartedAt.Add(quiz.RevealDuration()),
+		}
+		if err := controls.waitForDeadline(ctx, models.RevealPhase, reveal); err != nil {
+			return err
+		}
 	}
-	if err := workflow.ExecuteActivity(ctx, EndQuiz, quiz.Id).Get(ctx, nil); err != nil {
+	if err := workflow.ExecuteActivity(ctx, EndQuiz, sessionId).Get(ctx, nil); err != nil {
 		return err
 	}
+	status.Phase, status.Deadline = models.EndedPhase, time.Time{}
 	return nil
 }
 
-func StartQuiz(ctx context.Context, quizId models.QuizId) error {
-	return managers.StartQuiz(ctx, quizId)
+// hostControls receives the signals sent by the host of the quiz session,
+// and keeps the status returned by the status query up to date.
+type hostControls struct {
+	pause   workflow.ReceiveChannel
+	resume  workflow.ReceiveChannel
+	skip    workflow.ReceiveChannel
+	addTime workflow.ReceiveChannel
+	begin   workflow.ReceiveChannel
+	status  *models.QuizSessionStatus
+}
+
+func newHostControls(ctx workflow.Context, status *models.QuizSessionStatus) *hostControls {
+	return &hostControls{
+		pause:   workflow.GetSignalChannel(ctx, PauseSignal),
+		resume:  workflow.GetSignalChannel(ctx, ResumeSignal),
+		skip:    workflow.GetSignalChannel(ctx, SkipSignal),
+		addTime: workflow.GetSignalChannel(ctx, AddTimeSignal),
+		begin:   workflow.GetSignalChannel(ctx, BeginSignal),
+		status:  status,
+	}
+}
+
+// updateStatus sets the status of the lobby, the question or its reveal.
+func (h *hostControls) updateStatus(phase models.SessionPhase, payload *newQuestionPayload, paused bool, remaining time.Duration) {
+	h.status.Phase = phase
+	h.status.QuestionIndex = payload.CurrentQuestionIndex
+	h.status.Deadline = payload.Deadline
+	h.status.Paused = paused
+	h.status.TimeRemainingMs = remaining.Milliseconds()
+}
+
+// waitForDeadline waits until the deadline of the lobby, the question or its reveal, applying the host signals in the meantime.
+// While the quiz is paused the countdown stops, and the answer window is shifted by the pause duration on resume.
+// The quiz resumes automatically after configs.MaxPauseDuration.
+// Time can't be added to the reveal.
+// A skip only ends the phase in progress when it is received.
+func (h *hostControls) waitForDeadline(ctx workflow.Context, phase models.SessionPhase, payload *newQuestionPayload) error {
+	paused := false
+	var pausedAt time.Time
+	h.updateStatus(phase, payload, false, 0)
+	// the skips received before the phase started were meant for the previous one, eg a double click of the host
+	for h.skip.ReceiveAsync(nil) {
+	}
+	for {
+		if !paused && !workflow.Now(ctx).Before(payload.Deadline) {
+			return nil
+		}
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		timeout := payload.Deadline.Sub(workflow.Now(ctx))
+		if paused {
+			timeout = pausedAt.Add(configs.MaxPauseDuration).Sub(workflow.Now(ctx))
+		}
+		var err error
+		skipped, resumed := false, false
+		selector := workflow.NewSelector(ctx)
+		selector.AddFuture(workflow.NewTimer(timerCtx, timeout), func(f workflow.Future) {
+			// the question deadline, or the end of the maximum pause
+			resumed = paused
+		})
+		selector.AddReceive(h.pause, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			if paused {
+				return
+			}
+			paused, pausedAt = true, workflow.Now(ctx)
+			h.updateStatus(phase, payload, true, payload.Deadline.Sub(pausedAt))
+			err = workflow.ExecuteActivity(ctx, PauseQuiz, &hostActionPayload{
+				SessionId:            payload.SessionId,
+				CurrentQuestionIndex: payload.CurrentQuestionIndex,
+				Remaining:            payload.Deadline.Sub(pausedAt),
+			}).Get(ctx, nil)
+		})
+		selector.AddReceive(h.resume, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			resumed = paused
+		})
+		selector.AddReceive(h.skip, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			skipped = true
+		})
+		selector.AddReceive(h.begin, func(c workflow.ReceiveChannel, more bool) {
+			c.Receive(ctx, nil)
+			skipped = phase == models.LobbyPhase
+		})
+		selector.AddReceive(h.addTime, func(c workflow.ReceiveChannel, more bool) {
+			var seconds int
+			c.Receive(ctx, &seconds)
+			if seconds <= 0 || phase == models.RevealPhase {
+				return
+			}
+			added := time.Duration(seconds) * time.Second
+			payload.Deadline = payload.Deadline.Add(added)
+			remainingFrom := workflow.Now(ctx)
+			if paused {
+				remainingFrom = pausedAt
+			}
+			h.updateStatus(phase, payload, paused, payload.Deadline.Sub(remainingFrom))
+			err = workflow.ExecuteActivity(ctx, ExtendQuestion, &hostActionPayload{
+				SessionId:            payload.SessionId,
+				CurrentQuestionIndex: payload.CurrentQuestionIndex,
+				Deadline:             payload.Deadline,
+				Remaining:            payload.Deadline.Sub(remainingFrom),
+				Added:                added,
+			}).Get(ctx, nil)
+		})
+		selector.Select(ctx)
+		cancelTimer()
+		if ctx.Err() != nil {
+			// the session is aborted
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		if skipped {
+			if paused {
+				// the quiz lock was extended by the maximum pause duration
+				err = workflow.ExecuteActivity(ctx, CancelPause, &hostActionPayload{
+					SessionId:            payload.SessionId,
+					CurrentQuestionIndex: payload.CurrentQuestionIndex,
+					Remaining:            workflow.Now(ctx).Sub(pausedAt),
+				}).Get(ctx, nil)
+			}
+			return err
+		}
+		if resumed {
+			paused = false
+			pausedFor := workflow.Now(ctx).Sub(pausedAt)
+			payload.StartedAt = payload.StartedAt.Add(pausedFor)
+			payload.Deadline = payload.Deadline.Add(pausedFor)
+			h.updateStatus(phase, payload, false, 0)
+			resumed := *payload
+			resumed.PausedFor, resumed.Phase = pausedFor, phase
+			if err = workflow.ExecuteActivity(ctx, ResumeQuiz, &resumed).Get(ctx, nil); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func StartQuiz(ctx context.Context, payload startQuizPayload) error {
+	return managers.StartQuiz(ctx, payload.SessionId, payload.QuizId, payload.LockDuration)
 }
 
 func StartNewQuestion(ctx context.Context, payload newQuestionPayload) error {
-	return managers.StartNewQuestion(ctx, payload.QuizId, payload.CurrentQuestionIndex)
+	return managers.StartNewQuestion(ctx, payload.SessionId, payload.CurrentQuestionIndex, payload.StartedAt, payload.Deadline)
+}
+
+func CloseQuestion(ctx context.Context, payload questionPayload) error {
+	return managers.CloseQuestion(ctx, payload.SessionId, payload.CurrentQuestionIndex)
+}
+
+func EndQuestion(ctx context.Context, payload questionPayload) error {
+	return managers.EndQuestion(ctx, payload.SessionId, payload.CurrentQuestionIndex, payload.Question)
+}
+
+func EndQuiz(ctx context.Context, sessionId models.SessionId) error {
+	return managers.EndQuiz(ctx, sessionId, resultsManager)
+}
+
+func AbortQuiz(ctx context.Context, sessionId models.SessionId) error {
+	return managers.AbortQuiz(ctx, sessionId)
+}
+
+func PauseQuiz(ctx context.Context, payload hostActionPayload) error {
+	return managers.PauseQuiz(ctx, payload.SessionId, payload.CurrentQuestionIndex, payload.Remaining)
+}
+
+func ResumeQuiz(ctx context.Context, payload newQuestionPayload) error {
+	return managers.ResumeQuiz(ctx, payload.SessionId, payload.CurrentQuestionIndex, payload.StartedAt, payload.Deadline,
+		payload.PausedFor, payload.Phase == models.QuestionPhase)
+}
+
+// CancelPause is run when the host skips a paused phase, Remaining being the time it has been paused.
+func CancelPause(ctx context.Context, payload hostActionPayload) error {
+	return managers.CancelPause(ctx, payload.SessionId, payload.CurrentQuestionIndex, payload.Remaining)
 }
 
-func EndQuiz(ctx context.Context, quizId models.QuizId) error {
-	return managers.EndQuiz(ctx, quizId)
+func ExtendQuestion(ctx context.Context, payload hostActionPayload) error {
+	return managers.ExtendQuestion(ctx, payload.SessionId, payload.CurrentQuestionIndex,
+		payload.Deadline, payload.Remaining, payload.Added)
 }
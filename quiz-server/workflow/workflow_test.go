@@ -1,22 +1,173 @@
 package workflow
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/testsuite"
+	"quiz/configs"
+	"quiz/core/models"
 )
 
-func Test_Workflow(t *testing.T) {
-	// Set up the test suite and testing execution environment
-	//testSuite := &testsuite.WorkflowTestSuite{}
-	//env := testSuite.NewTestWorkflowEnvironment()
-	//
-	//// Mock activity implementation
-	//env.OnActivity(ComposeGreeting, mock.Anything, "World").Return("Hello World!", nil)
-	//
-	//env.ExecuteWorkflow(GreetingWorkflow, "World")
-	//require.True(t, env.IsWorkflowCompleted())
-	//require.NoError(t, env.GetWorkflowError())
-	//
-	//var greeting string
-	//require.NoError(t, env.GetWorkflowResult(&greeting))
-	//require.Equal(t, "Hello World!", greeting)
+// sessionTest runs the session workflow with mocked activities, recording their payloads and when they ran.
+type sessionTest struct {
+	env       *testsuite.TestWorkflowEnvironment
+	startedAt time.Time
+	questions []newQuestionPayload
+	resumed   []newQuestionPayload
+	cancelled []hostActionPayload
+	extended  []hostActionPayload
+	closedAt  []time.Time
+	aborted   bool
+}
+
+// newSessionTest mocks the activities, PauseQuiz taking pauseLatency to complete.
+func newSessionTest(pauseLatency time.Duration) *sessionTest {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	s := &sessionTest{env: testSuite.NewTestWorkflowEnvironment()}
+	env := s.env
+	for _, activity := range []any{StartQuiz, StartNewQuestion, CloseQuestion, EndQuestion, EndQuiz, AbortQuiz,
+		PauseQuiz, ResumeQuiz, CancelPause, ExtendQuestion} {
+		env.RegisterActivity(activity)
+	}
+	env.OnActivity(StartQuiz, mock.Anything, mock.Anything).Return(func(ctx context.Context, payload startQuizPayload) error {
+		s.startedAt = env.Now()
+		return nil
+	})
+	env.OnActivity(StartNewQuestion, mock.Anything, mock.Anything).Return(func(ctx context.Context, payload newQuestionPayload) error {
+		s.questions = append(s.questions, payload)
+		return nil
+	})
+	env.OnActivity(CloseQuestion, mock.Anything, mock.Anything).Return(func(ctx context.Context, payload questionPayload) error {
+		s.closedAt = append(s.closedAt, env.Now())
+		return nil
+	})
+	env.OnActivity(EndQuestion, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(EndQuiz, mock.Anything, mock.Anything).Return(nil)
+	env.OnActivity(AbortQuiz, mock.Anything, mock.Anything).Return(func(ctx context.Context, sessionId models.SessionId) error {
+		s.aborted = true
+		return nil
+	})
+	env.OnActivity(PauseQuiz, mock.Anything, mock.Anything).After(pauseLatency).Return(nil)
+	env.OnActivity(ResumeQuiz, mock.Anything, mock.Anything).Return(func(ctx context.Context, payload newQuestionPayload) error {
+		s.resumed = append(s.resumed, payload)
+		return nil
+	})
+	env.OnActivity(CancelPause, mock.Anything, mock.Anything).Return(func(ctx context.Context, payload hostActionPayload) error {
+		s.cancelled = append(s.cancelled, payload)
+		return nil
+	})
+	env.OnActivity(ExtendQuestion, mock.Anything, mock.Anything).Return(func(ctx context.Context, payload hostActionPayload) error {
+		s.extended = append(s.extended, payload)
+		return nil
+	})
+	return s
+}
+
+// requireTime checks the instant, whatever the location of the times.
+func requireTime(t *testing.T, expected, actual time.Time) {
+	t.Helper()
+	require.WithinDuration(t, expected, actual, 0)
+}
+
+// signal sends the signal to the workflow the given time after it started.
+func (s *sessionTest) signal(after time.Duration, signal string, arg any) {
+	s.env.RegisterDelayedCallback(func() {
+		s.env.SignalWorkflow(signal, arg)
+	}, after)
+}
+
+// run runs a session of 2 questions of 10 seconds, revealed for 5 seconds, whose lobby the host ends after 1 second.
+func (s *sessionTest) run() {
+	quiz := &models.Quiz{
+		Id:              1,
+		Questions:       []models.Question{{Content: "first"}, {Content: "second"}},
+		LobbySeconds:    10,
+		QuestionSeconds: 10,
+		RevealSeconds:   5,
+	}
+	s.signal(time.Second, BeginSignal, nil)
+	s.env.ExecuteWorkflow(QuizSessionWorkflow, models.NewSessionId(1, 1), quiz)
+}
+
+func TestWorkflowResumeShiftsDeadline(t *testing.T) {
+	s := newSessionTest(0)
+	s.signal(3*time.Second, PauseSignal, nil)
+	s.signal(8*time.Second, ResumeSignal, nil)
+	s.run()
+
+	require.True(t, s.env.IsWorkflowCompleted())
+	require.NoError(t, s.env.GetWorkflowError())
+	require.Len(t, s.questions, 2)
+	requireTime(t, s.startedAt.Add(11*time.Second), s.questions[0].Deadline)
+	require.Len(t, s.resumed, 1)
+	resumed := s.resumed[0]
+	require.Equal(t, 5*time.Second, resumed.PausedFor)
+	require.Equal(t, models.QuestionPhase, resumed.Phase)
+	requireTime(t, s.startedAt.Add(6*time.Second), resumed.StartedAt)
+	requireTime(t, s.startedAt.Add(16*time.Second), resumed.Deadline)
+	// the question is closed after the grace period of the shifted deadline
+	requireTime(t, resumed.Deadline.Add(configs.AnswerGracePeriod), s.closedAt[0])
+}
+
+func TestWorkflowSkipWhilePaused(t *testing.T) {
+	s := newSessionTest(0)
+	s.signal(3*time.Second, PauseSignal, nil)
+	s.signal(4*time.Second, SkipSignal, nil)
+	s.run()
+
+	require.True(t, s.env.IsWorkflowCompleted())
+	require.NoError(t, s.env.GetWorkflowError())
+	require.Empty(t, s.resumed)
+	// the lock extension of the pause is reduced to the time paused
+	require.Len(t, s.cancelled, 1)
+	require.Equal(t, time.Second, s.cancelled[0].Remaining)
+	// a skipped question is closed without grace period
+	requireTime(t, s.startedAt.Add(4*time.Second), s.closedAt[0])
+}
+
+func TestWorkflowDoubleSkipDuringActivity(t *testing.T) {
+	s := newSessionTest(time.Second)
+	s.signal(3*time.Second, PauseSignal, nil)
+	// both skips are buffered while the pause activity runs
+	s.signal(3500*time.Millisecond, SkipSignal, nil)
+	s.signal(3500*time.Millisecond, SkipSignal, nil)
+	s.run()
+
+	require.True(t, s.env.IsWorkflowCompleted())
+	require.NoError(t, s.env.GetWorkflowError())
+	requireTime(t, s.startedAt.Add(4*time.Second), s.closedAt[0])
+	// the second skip is not applied to the reveal, which lasts its 5 seconds
+	require.Len(t, s.questions, 2)
+	requireTime(t, s.startedAt.Add(9*time.Second), s.questions[1].StartedAt)
+}
+
+func TestWorkflowExtendQuestion(t *testing.T) {
+	s := newSessionTest(0)
+	s.signal(3*time.Second, AddTimeSignal, 5)
+	s.run()
+
+	require.True(t, s.env.IsWorkflowCompleted())
+	require.NoError(t, s.env.GetWorkflowError())
+	require.Len(t, s.extended, 1)
+	extended := s.extended[0]
+	require.Equal(t, 5*time.Second, extended.Added)
+	requireTime(t, s.startedAt.Add(16*time.Second), extended.Deadline)
+	require.Equal(t, 13*time.Second, extended.Remaining)
+	requireTime(t, extended.Deadline.Add(configs.AnswerGracePeriod), s.closedAt[0])
+}
+
+func TestWorkflowAbort(t *testing.T) {
+	s := newSessionTest(0)
+	s.env.RegisterDelayedCallback(s.env.CancelWorkflow, 3*time.Second)
+	s.run()
+
+	require.True(t, s.env.IsWorkflowCompleted())
+	require.Error(t, s.env.GetWorkflowError())
+	require.True(t, s.aborted)
+	require.Len(t, s.questions, 1)
+	require.Empty(t, s.closedAt)
 }